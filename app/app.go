@@ -2,48 +2,246 @@ package app
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/katakeda/lantrn-api-go/config"
+	"github.com/katakeda/lantrn-api-go/health"
+	"github.com/katakeda/lantrn-api-go/metrics"
+	"github.com/katakeda/lantrn-api-go/middlewares"
+	"github.com/katakeda/lantrn-api-go/poller"
+	"github.com/katakeda/lantrn-api-go/recgov"
 	"github.com/katakeda/lantrn-api-go/repositories"
 	"github.com/katakeda/lantrn-api-go/services"
 )
 
 type App struct {
-	router *gin.Engine
+	config    *config.Config
+	db        *pgxpool.Pool
+	readDB    *pgxpool.Pool
+	repo      *repositories.Repository
+	router    *gin.Engine
+	scheduler *poller.Scheduler
 }
 
-func (app *App) Initialize() {
-	db, err := pgxpool.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+// Initialize connects to the database and builds the router, and the
+// scheduler with schedulerConfig when the poller is enabled.
+func (app *App) Initialize(cfg *config.Config, schedulerConfig poller.SchedulerConfig) {
+	app.config = cfg
+
+	db, err := connect(cfg, cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalln("Failed to connect with DB", err)
+		fatal("Failed to connect with DB", err)
+	}
+	app.db = db
+
+	repoOpts := []repositories.Option{
+		repositories.WithCursorSecret([]byte(cfg.CursorSecret)),
+		repositories.WithDefaultPerPage(cfg.DefaultPerPage),
+		repositories.WithMaxPerPage(cfg.MaxPerPage),
+		repositories.WithRadius(cfg.SearchRadius),
+	}
+	if cfg.DatabaseReadURL != "" {
+		readDB, err := connect(cfg, cfg.DatabaseReadURL)
+		if err != nil {
+			fatal("Failed to connect with read DB", err)
+		}
+		app.readDB = readDB
+		repoOpts = append(repoOpts, repositories.WithReadDB(readDB))
 	}
 
-	repo, err := repositories.NewRepository(db)
+	repo, err := repositories.NewRepository(db, repoOpts...)
 	if err != nil {
-		log.Fatalln("Failed to initialize repository", err)
+		fatal("Failed to initialize repository", err)
+	}
+	app.repo = repo
+
+	pools := map[string]*pgxpool.Pool{"primary": db}
+	if app.readDB != nil {
+		pools["replica"] = app.readDB
+	}
+	if err := metrics.RegisterPools(pools); err != nil {
+		fatal("Failed to register pool metrics", err)
 	}
+	instrumented := metrics.InstrumentRepository(repo)
 
-	svc, err := services.NewService(repo)
+	svc, err := services.NewService(instrumented)
 	if err != nil {
-		log.Fatalln("Failed to initialize service", err)
+		fatal("Failed to initialize service", err)
 	}
 
-	app.router = gin.Default()
-	app.router.GET("/facilities", svc.GetFacilities)
-	app.router.GET("/facilities/:id", svc.GetFacility)
-	app.router.GET("/subscriptions", svc.GetSubscriptions)
-	app.router.POST("/subscriptions", svc.CreateSubscription)
-	app.router.PUT("/subscriptions/:id", svc.UpdateSubscription)
-	app.router.GET("/subscription_tokens", svc.GetSubscriptionTokens)
-	app.router.POST("/subscription_tokens", svc.CreateSubscriptionToken)
+	if cfg.PollerEnabled {
+		opts := []recgov.Option{recgov.WithRateLimit(cfg.RecGovRateLimit)}
+		if cfg.RecGovBaseURL != "" {
+			opts = append(opts, recgov.WithBaseURL(cfg.RecGovBaseURL))
+		}
+
+		p, err := poller.NewPoller(instrumented, poller.NewRecGovFetcher(recgov.NewClient(opts...)), nil)
+		if err != nil {
+			fatal("Failed to initialize poller", err)
+		}
+
+		app.scheduler, err = poller.NewScheduler(instrumented, p, schedulerConfig)
+		if err != nil {
+			fatal("Failed to initialize scheduler", err)
+		}
+	}
+
+	checks, err := app.readinessChecks()
+	if err != nil {
+		fatal("Failed to initialize readiness checks", err)
+	}
+
+	app.router = NewRouter(svc, checks...)
 }
 
-func (app *App) Run() {
-	err := app.router.Run(":8080")
+func connect(cfg *config.Config, url string) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(url)
 	if err != nil {
-		log.Fatalln("Failed to run app", err)
+		return nil, err
 	}
+	poolConfig.MaxConns = int32(cfg.DatabaseMaxConns)
+	poolConfig.MinConns = int32(cfg.DatabaseMinConns)
+	poolConfig.ConnConfig.ConnectTimeout = cfg.DatabaseConnectTimeout
+
+	return pgxpool.ConnectConfig(context.Background(), poolConfig)
+}
+
+// NewRouter builds the HTTP API on top of svc, with checks deciding readiness.
+// It does no I/O of its own, so it can be served by httptest with any
+// repository behind svc.
+func NewRouter(svc *services.Service, checks ...health.Check) *gin.Engine {
+	router := gin.New()
+	// Handlers pass the gin context on as a context.Context, which then has
+	// to reach the request context for the request ID and cancellation.
+	router.ContextWithFallback = true
+	// Metrics wrap Recovery so requests that panic are counted as the 500s they
+	// are answered with.
+	router.Use(middlewares.RequestId(), middlewares.Logger(), metrics.HTTP(), middlewares.Recovery(), middlewares.ErrorHandler())
+	router.GET("/healthz", health.Healthz)
+	router.GET("/readyz", health.Readyz(checks...))
+	router.GET("/version", health.Version)
+
+	registerRoutes(router.Group("/v1", middlewares.APIVersion(middlewares.APIVersion1)), svc)
+	registerRoutes(router.Group("/v2", middlewares.APIVersion(middlewares.APIVersion2)), svc)
+
+	// Unversioned routes predate /v1 and are kept for existing clients.
+	registerRoutes(router.Group("", middlewares.Deprecated("/v1")), svc)
+
+	return router
+}
+
+func registerRoutes(router *gin.RouterGroup, svc *services.Service) {
+	router.GET("/facilities", svc.GetFacilities)
+	router.GET("/facilities/:id", svc.GetFacility)
+	router.GET("/facilities/:id/campsites", svc.GetCampsites)
+	router.GET("/facilities/:id/availability", svc.GetAvailability)
+	router.GET("/facilities/:id/availability/history", svc.GetAvailabilityHistory)
+	router.GET("/subscriptions", svc.GetSubscriptions)
+	router.GET("/subscriptions/:id", svc.GetSubscription)
+	router.POST("/subscriptions", svc.CreateSubscription)
+	router.PUT("/subscriptions/:id", svc.UpdateSubscription)
+	router.GET("/subscription_tokens", svc.GetSubscriptionTokens)
+	router.POST("/subscription_tokens", svc.CreateSubscriptionToken)
+}
+
+// Run serves the API, and metrics on their own listener, until SIGINT or
+// SIGTERM, then drains in-flight requests and stops the scheduler within the
+// shutdown timeout before closing the pools.
+func (app *App) Run() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:         app.config.ListenAddr,
+		Handler:      app.router,
+		ReadTimeout:  app.config.HTTPReadTimeout,
+		WriteTimeout: app.config.HTTPWriteTimeout,
+		IdleTimeout:  app.config.HTTPIdleTimeout,
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+	metricsServer := &http.Server{
+		Addr:         app.config.MetricsAddr,
+		Handler:      metricsMux,
+		ReadTimeout:  app.config.HTTPReadTimeout,
+		WriteTimeout: app.config.HTTPWriteTimeout,
+		IdleTimeout:  app.config.HTTPIdleTimeout,
+	}
+	servers := []*http.Server{server, metricsServer}
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	workersDone := make(chan struct{})
+	go func() {
+		defer close(workersDone)
+		if app.scheduler != nil {
+			app.scheduler.Run(workerCtx)
+		}
+	}()
+
+	serverErr := make(chan error, len(servers))
+	for _, s := range servers {
+		go func(s *http.Server) {
+			serverErr <- s.ListenAndServe()
+		}(s)
+	}
+
+	select {
+	case err := <-serverErr:
+		stopWorkers()
+		<-workersDone
+		app.close()
+		fatal("Failed to run app", err)
+	case <-ctx.Done():
+		stop()
+		slog.Info("Shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.config.ShutdownTimeout)
+	defer cancel()
+
+	// Workers are stopped alongside the server rather than after it, so both
+	// get the whole timeout.
+	stopWorkers()
+
+	for _, s := range servers {
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to drain requests", "addr", s.Addr, "error", err)
+		}
+	}
+	for range servers {
+		if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Failed to run app", "error", err)
+		}
+	}
+
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		slog.Error("Failed to stop workers", "error", shutdownCtx.Err())
+	}
+
+	app.close()
+	slog.Info("Shut down")
+}
+
+func (app *App) close() {
+	if app.readDB != nil {
+		app.readDB.Close()
+	}
+	app.db.Close()
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,734 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/katakeda/lantrn-api-go/app"
+	"github.com/katakeda/lantrn-api-go/app/apptest"
+	"github.com/katakeda/lantrn-api-go/errs"
+	"github.com/katakeda/lantrn-api-go/middlewares"
+	"github.com/katakeda/lantrn-api-go/repositories"
+	"github.com/katakeda/lantrn-api-go/repositories/repotest"
+	"github.com/katakeda/lantrn-api-go/services"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// listBody is a listing as version 2 renders it. Version 1 has the same
+// data but only page and total in its metadata.
+type listBody struct {
+	Data     []json.RawMessage          `json:"data"`
+	Metadata map[string]json.RawMessage `json:"metadata"`
+}
+
+type fixture struct {
+	server    *apptest.Server
+	upper     *repositories.Facility
+	north     *repositories.Facility
+	blackwood *repositories.Facility
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	server, err := apptest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	f := &fixture{server: server}
+	for _, seed := range []struct {
+		facility repositories.Facility
+		out      **repositories.Facility
+	}{
+		{repotest.UpperPines, &f.upper},
+		{repotest.NorthPines, &f.north},
+		{repotest.Blackwoods, &f.blackwood},
+	} {
+		facility, err := server.Repository.AddFacility(context.Background(), seed.facility)
+		if err != nil {
+			t.Fatalf("AddFacility: %v", err)
+		}
+		*seed.out = facility
+	}
+
+	return f
+}
+
+// do sends a request and returns the status and raw body, failing the test
+// when the request can't be made.
+func (f *fixture) do(t *testing.T, method, path string, body interface{}) (*http.Response, json.RawMessage) {
+	t.Helper()
+
+	var raw json.RawMessage
+	res, err := f.server.Do(method, path, body, &raw)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return res, raw
+}
+
+func (f *fixture) list(t *testing.T, path string) listBody {
+	t.Helper()
+
+	res, raw := f.do(t, http.MethodGet, path, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s = %d %s, want 200", path, res.StatusCode, raw)
+	}
+	return decode[listBody](t, raw)
+}
+
+func (f *fixture) createSubscription(t *testing.T, payload repositories.CreateSubscriptionPayload) repositories.Subscription {
+	t.Helper()
+
+	res, raw := f.do(t, http.MethodPost, "/v2/subscriptions", payload)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /v2/subscriptions = %d %s, want 201", res.StatusCode, raw)
+	}
+	return decode[repositories.Subscription](t, raw)
+}
+
+func TestFacilities(t *testing.T) {
+	f := newFixture(t)
+
+	t.Run("shape", func(t *testing.T) {
+		v1 := f.list(t, "/v1/facilities")
+		assertKeys(t, v1.Metadata, "page", "total")
+		v2 := f.list(t, "/v2/facilities")
+		assertKeys(t, v2.Metadata, "page", "perPage", "total", "totalPages", "hasNext")
+		for _, body := range []listBody{v1, v2} {
+			assertNames(t, body.Data, "Blackwoods Campground", "North Pines", "Upper Pines")
+		}
+		assertKeys(t, v1.Data[0], "id", "name", "description", "latitude", "longitude", "facilityId", "primaryImg")
+		assertKeys(t, v2.Data[0], "id", "name", "description", "latitude", "longitude", "facilityId", "createdAt", "updatedAt", "primaryImg")
+		if page, total := decode[int](t, v2.Metadata["page"]), decode[int](t, v2.Metadata["total"]); page != 1 || total != 3 {
+			t.Errorf("v2 page %d total %d, want page 1 of 3 facilities", page, total)
+		}
+	})
+
+	t.Run("v1 metadata", func(t *testing.T) {
+		// Version 1 reports the page as asked for, 0 when it wasn't.
+		for path, want := range map[string]string{
+			"/v1/facilities":                                 `{"page":0,"total":3}`,
+			"/v1/facilities?page=1":                          `{"page":1,"total":3}`,
+			"/v1/facilities?per_page=2&page=2":               `{"page":2,"total":3}`,
+			"/v1/facilities?ids=" + strconv.Itoa(f.upper.Id): `{"page":0,"total":1}`,
+		} {
+			_, raw := f.do(t, http.MethodGet, path, nil)
+			var body struct {
+				Metadata json.RawMessage `json:"metadata"`
+			}
+			if err := json.Unmarshal(raw, &body); err != nil {
+				t.Fatalf("GET %s: %v", path, err)
+			}
+			if string(body.Metadata) != want {
+				t.Errorf("GET %s metadata = %s, want %s", path, body.Metadata, want)
+			}
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		for _, prefix := range []string{"/v1", "/v2"} {
+			assertNames(t, f.list(t, fmt.Sprintf("%s/facilities?ids=%d,%d", prefix, f.upper.Id, f.blackwood.Id)).Data, "Blackwoods Campground", "Upper Pines")
+			assertNames(t, f.list(t, fmt.Sprintf("%s/facilities?lat=%v&lng=%v", prefix, *repotest.UpperPines.Latitude, *repotest.UpperPines.Longitude)).Data, "North Pines", "Upper Pines")
+			assertNames(t, f.list(t, prefix+"/facilities?sort=za").Data, "Upper Pines", "North Pines", "Blackwoods Campground")
+			assertNames(t, f.list(t, prefix+"/facilities?sort=new").Data, "Blackwoods Campground", "North Pines", "Upper Pines")
+
+			sparse := f.list(t, prefix+"/facilities?fields=id,name")
+			for _, item := range sparse.Data {
+				assertKeys(t, item, "id", "name")
+			}
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		second := f.list(t, "/v2/facilities?per_page=2&page=2")
+		assertNames(t, second.Data, "Upper Pines")
+		if page, totalPages, hasNext := decode[int](t, second.Metadata["page"]), decode[int](t, second.Metadata["totalPages"]), decode[bool](t, second.Metadata["hasNext"]); page != 2 || totalPages != 2 || hasNext {
+			t.Errorf("page %d of %d, hasNext %v, want the last of 2 pages", page, totalPages, hasNext)
+		}
+
+		first := f.list(t, "/v2/facilities?per_page=2")
+		assertNames(t, first.Data, "Blackwoods Campground", "North Pines")
+		next := decode[string](t, first.Metadata["next"])
+		if next == "" {
+			t.Fatal("first page has no next cursor")
+		}
+		following := f.list(t, "/v2/facilities?per_page=2&cursor="+next)
+		assertNames(t, following.Data, "Upper Pines")
+
+		// The cursor belongs to the unfiltered listing.
+		assertError(t, f, http.MethodGet, fmt.Sprintf("/v2/facilities?per_page=2&ids=%d&cursor=%s", f.upper.Id, next), nil, http.StatusBadRequest, errs.CodeInvalidArgument, "Cursor was issued for different filters")
+
+		v1 := f.list(t, "/v1/facilities?per_page=2&page=2")
+		assertNames(t, v1.Data, "Upper Pines")
+		if page := decode[int](t, v1.Metadata["page"]); page != 2 {
+			t.Errorf("v1 page = %d, want 2", page)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		res, raw := f.do(t, http.MethodGet, "/v1/facilities?ids=999", nil)
+		if res.StatusCode != http.StatusNotFound || decode[string](t, raw) != "No facilities found" {
+			t.Errorf("v1 empty = %d %s, want 404 No facilities found", res.StatusCode, raw)
+		}
+
+		empty := f.list(t, "/v2/facilities?ids=999")
+		if len(empty.Data) != 0 || decode[int](t, empty.Metadata["total"]) != 0 {
+			t.Errorf("v2 empty = %+v, want no facilities", empty)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for path, message := range map[string]string{
+			"/facilities?per_page=abc":  "Per page must be a number",
+			"/facilities?page=0":        "Page must be 1 or greater",
+			"/facilities?fields=nope":   `Unknown field "nope"`,
+			"/facilities?cursor=forged": "Invalid cursor",
+		} {
+			assertError(t, f, http.MethodGet, path, nil, http.StatusBadRequest, errs.CodeInvalidArgument, message)
+			assertLegacyError(t, f, http.MethodGet, path, nil, http.StatusInternalServerError, "Something went wrong while getting facilities")
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		for _, prefix := range []string{"/v1", "/v2"} {
+			res, raw := f.do(t, http.MethodGet, fmt.Sprintf("%s/facilities/%d", prefix, f.north.Id), nil)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("GET facility = %d %s, want 200", res.StatusCode, raw)
+			}
+			if facility := decode[repositories.Facility](t, raw); facility.Id != f.north.Id || facility.Name != "North Pines" || facility.FacilityId != "232449" {
+				t.Errorf("facility = %+v, want North Pines", facility)
+			}
+			if prefix == "/v1" {
+				assertKeys(t, raw, "id", "name", "description", "latitude", "longitude", "facilityId", "primaryImg")
+			}
+		}
+
+		assertError(t, f, http.MethodGet, "/facilities/abc", nil, http.StatusBadRequest, errs.CodeInvalidArgument, "Facility id must be a number")
+		assertError(t, f, http.MethodGet, "/facilities/999", nil, http.StatusNotFound, errs.CodeNotFound, "Facility not found")
+		for _, path := range []string{"/facilities/abc", "/facilities/999"} {
+			assertLegacyError(t, f, http.MethodGet, path, nil, http.StatusInternalServerError, "Something went wrong while getting facility")
+		}
+	})
+}
+
+func TestCampsites(t *testing.T) {
+	f := newFixture(t)
+	seedCampsites(t, f)
+
+	for _, prefix := range []string{"/v1", "/v2"} {
+		all := f.list(t, fmt.Sprintf("%s/facilities/%d/campsites", prefix, f.upper.Id))
+		assertNames(t, all.Data, "001", "002", "077")
+		assertKeys(t, all.Data[0], "id", "name", "siteType", "maxOccupancy", "equipmentAllowed", "loop", "campsiteId", "facilityId", "createdAt", "updatedAt")
+
+		assertNames(t, f.list(t, fmt.Sprintf("%s/facilities/%d/campsites?site_type=TENT+ONLY", prefix, f.upper.Id)).Data, "077")
+		assertNames(t, f.list(t, fmt.Sprintf("%s/facilities/%d/campsites?loop=A", prefix, f.upper.Id)).Data, "001", "002")
+	}
+
+	res, raw := f.do(t, http.MethodGet, fmt.Sprintf("/v1/facilities/%d/campsites", f.north.Id), nil)
+	if res.StatusCode != http.StatusNotFound || decode[string](t, raw) != "No campsites found" {
+		t.Errorf("v1 empty = %d %s, want 404 No campsites found", res.StatusCode, raw)
+	}
+	if empty := f.list(t, fmt.Sprintf("/v2/facilities/%d/campsites", f.north.Id)); len(empty.Data) != 0 {
+		t.Errorf("v2 empty = %s, want no campsites", empty.Data)
+	}
+
+	assertError(t, f, http.MethodGet, fmt.Sprintf("/facilities/%d/campsites?page=x", f.upper.Id), nil, http.StatusBadRequest, errs.CodeInvalidArgument, "Page must be a number")
+	assertLegacyError(t, f, http.MethodGet, fmt.Sprintf("/facilities/%d/campsites?page=x", f.upper.Id), nil, http.StatusInternalServerError, "Something went wrong while getting campsites")
+}
+
+func TestAvailability(t *testing.T) {
+	f := newFixture(t)
+	campsites := seedCampsites(t, f)
+
+	from := time.Now().UTC().AddDate(0, 0, 10)
+	day := from.Format("2006-01-02")
+	observedAt := time.Now().UTC().Add(-time.Hour)
+	payloads := []repositories.CreateAvailabilitySnapshotPayload{
+		{FacilityId: f.upper.Id, CampsiteId: &campsites[0].Id, Date: day, Status: repositories.AvailabilityStatusReserved, ObservedAt: observedAt},
+		{FacilityId: f.upper.Id, CampsiteId: &campsites[0].Id, Date: day, Status: repositories.AvailabilityStatusAvailable, ObservedAt: observedAt.Add(time.Minute)},
+		{FacilityId: f.upper.Id, CampsiteId: &campsites[1].Id, Date: day, Status: repositories.AvailabilityStatusReserved, ObservedAt: observedAt},
+	}
+	if err := f.server.Repository.CreateAvailabilitySnapshots(context.Background(), payloads); err != nil {
+		t.Fatalf("CreateAvailabilitySnapshots: %v", err)
+	}
+
+	query := fmt.Sprintf("from=%s&to=%s", day, from.AddDate(0, 0, 1).Format("2006-01-02"))
+	for _, prefix := range []string{"/v1", "/v2"} {
+		res, raw := f.do(t, http.MethodGet, fmt.Sprintf("%s/facilities/%d/availability?%s", prefix, f.upper.Id, query), nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("GET availability = %d %s, want 200", res.StatusCode, raw)
+		}
+		availability := decode[repositories.GetAvailabilityResponse](t, raw)
+		if len(availability.Data) != 1 || availability.Data[0].Date != day || availability.Data[0].Available != 1 || len(availability.Data[0].Campsites) != 2 {
+			t.Errorf("availability = %+v, want one day with 1 of 2 sites open", availability.Data)
+		}
+
+		res, raw = f.do(t, http.MethodGet, fmt.Sprintf("%s/facilities/%d/availability/history?%s", prefix, f.upper.Id, query), nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("GET availability history = %d %s, want 200", res.StatusCode, raw)
+		}
+		assertKeys(t, raw, "data", "summary", "metadata")
+		history := decode[repositories.GetAvailabilityHistoryResponse](t, raw)
+		if len(history.Data) != 1 || history.Summary.Openings != 1 || history.Data[0].LeadDays != 10 {
+			t.Errorf("history = %+v, want one opening 10 days ahead", history)
+		}
+	}
+
+	assertError(t, f, http.MethodGet, fmt.Sprintf("/facilities/%d/availability?from=2030-02-01&to=2030-01-01", f.upper.Id), nil, http.StatusBadRequest, errs.CodeInvalidArgument, "from and to must be a valid date range")
+	assertError(t, f, http.MethodGet, fmt.Sprintf("/facilities/%d/availability/history?from=soon", f.upper.Id), nil, http.StatusBadRequest, errs.CodeInvalidArgument, "from and to must be a valid date range")
+	assertLegacyError(t, f, http.MethodGet, fmt.Sprintf("/facilities/%d/availability?from=2030-02-01&to=2030-01-01", f.upper.Id), nil, http.StatusInternalServerError, "Something went wrong while getting availability")
+	assertLegacyError(t, f, http.MethodGet, fmt.Sprintf("/facilities/%d/availability/history?from=soon", f.upper.Id), nil, http.StatusInternalServerError, "Something went wrong while getting availability history")
+}
+
+func TestSubscriptions(t *testing.T) {
+	f := newFixture(t)
+	targetDate := time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02")
+	paused := repositories.SubscriptionStatusPaused
+
+	t.Run("create", func(t *testing.T) {
+		payload := repositories.CreateSubscriptionPayload{Email: "camper@example.com", TargetDate: targetDate, FacilityId: f.upper.Id}
+
+		res, raw := f.do(t, http.MethodPost, "/v1/subscriptions", payload)
+		if res.StatusCode != http.StatusOK || res.Header.Get("Location") != "" {
+			t.Errorf("v1 create = %d with Location %q, want 200 without", res.StatusCode, res.Header.Get("Location"))
+		}
+		assertKeys(t, raw, "id", "email", "targetDate", "facilityId", "status")
+
+		res, raw = f.do(t, http.MethodPost, "/v2/subscriptions", payload)
+		assertKeys(t, raw, "id", "email", "targetDate", "facilityId", "campsiteIds", "siteType", "status", "createdAt", "updatedAt")
+		subscription := decode[repositories.Subscription](t, raw)
+		if want := fmt.Sprintf("/v2/subscriptions/%d", subscription.Id); res.StatusCode != http.StatusCreated || res.Header.Get("Location") != want {
+			t.Errorf("v2 create = %d with Location %q, want 201 at %s", res.StatusCode, res.Header.Get("Location"), want)
+		}
+		if subscription.Status == nil || *subscription.Status != repositories.SubscriptionStatusActive {
+			t.Errorf("status = %v, want %s", subscription.Status, repositories.SubscriptionStatusActive)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		payload := repositories.CreateSubscriptionPayload{Email: "Jane <jane@example.com>", TargetDate: "2000-01-01", FacilityId: 999}
+
+		assertLegacyError(t, f, http.MethodPost, "/subscriptions", payload, http.StatusInternalServerError, "Something went wrong while creating subscription")
+		// A body that can't be bound was a bad request in version 1 too.
+		assertLegacyError(t, f, http.MethodPost, "/subscriptions", map[string]interface{}{"facilityId": "one"}, http.StatusBadRequest, "Something went wrong while creating subscription")
+
+		res, raw := f.do(t, http.MethodPost, "/v2/subscriptions", payload)
+		body := decode[middlewares.ErrorResponse](t, raw)
+		if res.StatusCode != http.StatusUnprocessableEntity || body.Error.Code != errs.CodeValidation {
+			t.Fatalf("v2 invalid = %d %s, want 422 validation_failed", res.StatusCode, raw)
+		}
+		fields := decode[[]errs.FieldError](t, mustMarshal(t, body.Error.Details))
+		want := []errs.FieldError{
+			{Field: "email", Message: "must be a valid email address"},
+			{Field: "targetDate", Message: "must be in the future"},
+			{Field: "facilityId", Message: "does not exist"},
+		}
+		if !reflect.DeepEqual(fields, want) {
+			t.Errorf("details = %+v, want %+v", fields, want)
+		}
+
+		res, raw = f.do(t, http.MethodPost, "/v2/subscriptions", map[string]interface{}{"facilityId": "one"})
+		if body := decode[middlewares.ErrorResponse](t, raw); res.StatusCode != http.StatusUnprocessableEntity || body.Error.Code != errs.CodeValidation {
+			t.Errorf("v2 wrong type = %d %s, want 422 validation_failed", res.StatusCode, raw)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		f := newFixture(t)
+		first := f.createSubscription(t, repositories.CreateSubscriptionPayload{Email: "first@example.com", TargetDate: targetDate, FacilityId: f.upper.Id})
+		second := f.createSubscription(t, repositories.CreateSubscriptionPayload{Email: "second@example.com", TargetDate: targetDate, FacilityId: f.north.Id, Status: &paused})
+		third := f.createSubscription(t, repositories.CreateSubscriptionPayload{Email: "third@example.com", TargetDate: targetDate, FacilityId: f.upper.Id})
+
+		assertKeys(t, f.list(t, "/v1/subscriptions").Data[0], "id", "email", "targetDate", "facilityId", "status")
+		for _, prefix := range []string{"/v1", "/v2"} {
+			assertIds(t, f.list(t, prefix+"/subscriptions").Data, first.Id, second.Id, third.Id)
+			assertIds(t, f.list(t, fmt.Sprintf("%s/subscriptions?facility_ids=%d", prefix, f.upper.Id)).Data, first.Id, third.Id)
+			assertIds(t, f.list(t, prefix+"/subscriptions?status=paused").Data, second.Id)
+			assertIds(t, f.list(t, prefix+"/subscriptions?sort=new").Data, third.Id, second.Id, first.Id)
+
+			sparse := f.list(t, prefix+"/subscriptions?fields=id,email")
+			for _, item := range sparse.Data {
+				assertKeys(t, item, "id", "email")
+			}
+		}
+
+		page := f.list(t, "/v2/subscriptions?sort=old&per_page=2")
+		assertIds(t, page.Data, first.Id, second.Id)
+		next := decode[string](t, page.Metadata["next"])
+		assertIds(t, f.list(t, "/v2/subscriptions?sort=old&per_page=2&cursor="+next).Data, third.Id)
+		assertError(t, f, http.MethodGet, "/v2/subscriptions?sort=new&per_page=2&cursor="+next, nil, http.StatusBadRequest, errs.CodeInvalidArgument, "Cursor was issued for a different sort")
+
+		res, raw := f.do(t, http.MethodGet, "/v1/subscriptions?status=cancelled", nil)
+		if res.StatusCode != http.StatusNotFound || decode[string](t, raw) != "No subscriptions found" {
+			t.Errorf("v1 empty = %d %s, want 404 No subscriptions found", res.StatusCode, raw)
+		}
+		if empty := f.list(t, "/v2/subscriptions?status=cancelled"); len(empty.Data) != 0 {
+			t.Errorf("v2 empty = %s, want no subscriptions", empty.Data)
+		}
+		assertIds(t, f.list(t, "/v2/subscriptions?created_to=2000-01-01").Data)
+
+		assertError(t, f, http.MethodGet, "/subscriptions?created_from=yesterday", nil, http.StatusBadRequest, errs.CodeInvalidArgument, "Created from must be a date or timestamp")
+		assertLegacyError(t, f, http.MethodGet, "/subscriptions?created_from=yesterday", nil, http.StatusInternalServerError, "Something went wrong while getting subscriptions")
+	})
+
+	t.Run("get and update", func(t *testing.T) {
+		subscription := f.createSubscription(t, repositories.CreateSubscriptionPayload{Email: "camper@example.com", TargetDate: targetDate, FacilityId: f.upper.Id})
+		path := fmt.Sprintf("/subscriptions/%d", subscription.Id)
+
+		for _, prefix := range []string{"/v1", "/v2"} {
+			res, raw := f.do(t, http.MethodGet, prefix+path, nil)
+			if got := decode[repositories.Subscription](t, raw); res.StatusCode != http.StatusOK || got.Id != subscription.Id || got.Email != "camper@example.com" {
+				t.Errorf("GET %s = %d %s, want the subscription", prefix+path, res.StatusCode, raw)
+			}
+		}
+
+		res, raw := f.do(t, http.MethodPut, "/v1"+path, repositories.UpdateSubscriptionPayload{Status: &paused})
+		if updated := decode[repositories.Subscription](t, raw); res.StatusCode != http.StatusOK || updated.Status == nil || *updated.Status != paused {
+			t.Errorf("PUT /v1%s = %d %s, want it paused", path, res.StatusCode, raw)
+		}
+		assertKeys(t, raw, "id", "email", "targetDate", "facilityId", "status")
+
+		res, raw = f.do(t, http.MethodPut, "/v2"+path, repositories.UpdateSubscriptionPayload{Status: &paused})
+		if updated := decode[repositories.Subscription](t, raw); res.StatusCode != http.StatusOK || updated.Status == nil || *updated.Status != paused {
+			t.Errorf("PUT /v2%s = %d %s, want it paused", path, res.StatusCode, raw)
+		}
+
+		unknown := "archived"
+		assertError(t, f, http.MethodPut, path, repositories.UpdateSubscriptionPayload{Status: &unknown}, http.StatusUnprocessableEntity, errs.CodeValidation, "Invalid payload")
+		assertError(t, f, http.MethodPut, path, repositories.UpdateSubscriptionPayload{}, http.StatusUnprocessableEntity, errs.CodeValidation, "Invalid payload")
+		assertError(t, f, http.MethodPut, "/subscriptions/999", repositories.UpdateSubscriptionPayload{Status: &paused}, http.StatusNotFound, errs.CodeNotFound, "Subscription not found")
+		assertError(t, f, http.MethodGet, "/subscriptions/abc", nil, http.StatusBadRequest, errs.CodeInvalidArgument, "Subscription id must be a number")
+		assertLegacyError(t, f, http.MethodPut, path, repositories.UpdateSubscriptionPayload{Status: &unknown}, http.StatusInternalServerError, "Something went wrong while updating subscription")
+		assertLegacyError(t, f, http.MethodPut, "/subscriptions/999", repositories.UpdateSubscriptionPayload{Status: &paused}, http.StatusInternalServerError, "Something went wrong while updating subscription")
+		assertLegacyError(t, f, http.MethodGet, "/subscriptions/abc", nil, http.StatusInternalServerError, "Something went wrong while getting subscription")
+	})
+}
+
+func TestSubscriptionTokens(t *testing.T) {
+	f := newFixture(t)
+	targetDate := time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02")
+	first := f.createSubscription(t, repositories.CreateSubscriptionPayload{Email: "first@example.com", TargetDate: targetDate, FacilityId: f.upper.Id})
+	second := f.createSubscription(t, repositories.CreateSubscriptionPayload{Email: "second@example.com", TargetDate: targetDate, FacilityId: f.upper.Id})
+	unsubscribe := repositories.SubscriptionTokenPurposeUnsubscribe
+
+	res, raw := f.do(t, http.MethodPost, "/v1/subscription_tokens", repositories.CreateSubscriptionTokenPayload{SubscriptionId: first.Id})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("v1 create = %d %s, want 200", res.StatusCode, raw)
+	}
+	assertKeys(t, raw, "id", "subscriptionId", "token")
+	manage := decode[repositories.SubscriptionToken](t, raw)
+	if manage.Token == "" {
+		t.Errorf("token = %+v, want a token", manage)
+	}
+	assertIds(t, f.list(t, "/v2/subscription_tokens?purpose=manage").Data, manage.Id)
+
+	res, raw = f.do(t, http.MethodPost, "/v2/subscription_tokens", repositories.CreateSubscriptionTokenPayload{SubscriptionId: second.Id, Purpose: &unsubscribe})
+	assertKeys(t, raw, "id", "subscriptionId", "token", "purpose", "createdAt", "updatedAt")
+	token := decode[repositories.SubscriptionToken](t, raw)
+	if want := "/v2/subscription_tokens?token=" + token.Token; res.StatusCode != http.StatusCreated || res.Header.Get("Location") != want {
+		t.Errorf("v2 create = %d with Location %q, want 201 at %s", res.StatusCode, res.Header.Get("Location"), want)
+	}
+
+	assertKeys(t, f.list(t, "/v1/subscription_tokens").Data[0], "id", "subscriptionId", "token")
+	for _, prefix := range []string{"/v1", "/v2"} {
+		assertIds(t, f.list(t, prefix+"/subscription_tokens").Data, manage.Id, token.Id)
+		assertIds(t, f.list(t, prefix+"/subscription_tokens?token="+token.Token).Data, token.Id)
+		assertIds(t, f.list(t, fmt.Sprintf("%s/subscription_tokens?subscription_ids=%d", prefix, first.Id)).Data, manage.Id)
+		assertIds(t, f.list(t, prefix+"/subscription_tokens?purpose=unsubscribe").Data, token.Id)
+	}
+
+	page := f.list(t, "/v2/subscription_tokens?per_page=1")
+	assertIds(t, page.Data, manage.Id)
+	assertIds(t, f.list(t, "/v2/subscription_tokens?per_page=1&cursor="+decode[string](t, page.Metadata["next"])).Data, token.Id)
+
+	// An unknown token is just an empty listing in version 1.
+	for _, path := range []string{"/v1/subscription_tokens?purpose=verify", "/v1/subscription_tokens?token=unknown"} {
+		res, raw = f.do(t, http.MethodGet, path, nil)
+		if res.StatusCode != http.StatusNotFound || decode[string](t, raw) != "No subscription tokens found" {
+			t.Errorf("GET %s = %d %s, want 404 No subscription tokens found", path, res.StatusCode, raw)
+		}
+	}
+
+	bogus := "bogus"
+	assertError(t, f, http.MethodPost, "/subscription_tokens", repositories.CreateSubscriptionTokenPayload{SubscriptionId: first.Id, Purpose: &bogus}, http.StatusUnprocessableEntity, errs.CodeValidation, "Invalid payload")
+	assertError(t, f, http.MethodPost, "/subscription_tokens", repositories.CreateSubscriptionTokenPayload{}, http.StatusUnprocessableEntity, errs.CodeValidation, "Invalid payload")
+	assertLegacyError(t, f, http.MethodPost, "/subscription_tokens", repositories.CreateSubscriptionTokenPayload{}, http.StatusInternalServerError, "Something went wrong while creating subscription token")
+	assertLegacyError(t, f, http.MethodGet, "/subscription_tokens?created_from=never", nil, http.StatusInternalServerError, "Something went wrong while getting subscription tokens")
+}
+
+func TestUnversionedRoutes(t *testing.T) {
+	f := newFixture(t)
+
+	res, raw := f.do(t, http.MethodGet, "/facilities?ids=999", nil)
+	if res.StatusCode != http.StatusNotFound || decode[string](t, raw) != "No facilities found" {
+		t.Errorf("unversioned = %d %s, want the v1 response", res.StatusCode, raw)
+	}
+	if res.Header.Get("Deprecation") != "true" || res.Header.Get("Link") != `</v1/facilities>; rel="successor-version"` {
+		t.Errorf("Deprecation %q Link %q, want the route deprecated for /v1", res.Header.Get("Deprecation"), res.Header.Get("Link"))
+	}
+
+	// Clients can opt in to version 2 responses with a header.
+	req, err := http.NewRequest(http.MethodGet, f.server.URL+"/facilities?ids=999", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set(middlewares.APIVersionHeader, "2")
+	res, err = f.server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("unversioned with %s: 2 = %d, want 200", middlewares.APIVersionHeader, res.StatusCode)
+	}
+
+	res, _ = f.do(t, http.MethodGet, "/v2/facilities", nil)
+	if res.Header.Get("Deprecation") != "" {
+		t.Errorf("v2 Deprecation = %q, want none", res.Header.Get("Deprecation"))
+	}
+}
+
+func TestHealth(t *testing.T) {
+	f := newFixture(t)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		res, raw := f.do(t, http.MethodGet, path, nil)
+		if res.StatusCode != http.StatusOK || decode[map[string]string](t, raw)["status"] != "ok" {
+			t.Errorf("GET %s = %d %s, want 200 ok", path, res.StatusCode, raw)
+		}
+	}
+
+	res, raw := f.do(t, http.MethodGet, "/version", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("GET /version = %d, want 200", res.StatusCode)
+	}
+	assertKeys(t, raw, "commit", "buildTime", "goVersion")
+	if version := decode[map[string]string](t, raw); version["commit"] == "" {
+		t.Errorf("version = %s, want a commit", raw)
+	}
+
+	// Metrics are served by their own listener, never by the API.
+	if res, _ := f.do(t, http.MethodGet, "/metrics", nil); res.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /metrics = %d, want 404", res.StatusCode)
+	}
+}
+
+func TestRequestId(t *testing.T) {
+	f := newFixture(t)
+
+	req, err := http.NewRequest(http.MethodGet, f.server.URL+"/v2/facilities/999", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set(middlewares.RequestIdHeader, "client-request-1")
+	res, err := f.server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	defer res.Body.Close()
+
+	var body middlewares.ErrorResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if res.Header.Get(middlewares.RequestIdHeader) != "client-request-1" || body.Error.RequestId != "client-request-1" {
+		t.Errorf("request id header %q body %q, want the client's", res.Header.Get(middlewares.RequestIdHeader), body.Error.RequestId)
+	}
+}
+
+func TestPanicsAreCounted(t *testing.T) {
+	repo, err := repositories.NewMemoryRepository()
+	if err != nil {
+		t.Fatalf("NewMemoryRepository: %v", err)
+	}
+	svc, err := services.NewService(repo)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	router := app.NewRouter(svc)
+	router.GET("/panic", func(c *gin.Context) { panic("handler failed") })
+
+	before := requestsCounted(t, "/panic", "500")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if res.Code != http.StatusInternalServerError {
+		t.Fatalf("GET /panic = %d, want 500", res.Code)
+	}
+	if got := requestsCounted(t, "/panic", "500"); got != before+1 {
+		t.Errorf("counted %v requests to /panic answered with 500, want %v", got, before+1)
+	}
+}
+
+// requestsCounted returns lantrn_http_requests_total for GET requests to
+// route answered with status.
+func requestsCounted(t *testing.T, route, status string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "lantrn_http_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["method"] == http.MethodGet && labels["route"] == route && labels["status"] == status {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
+
+func seedCampsites(t *testing.T, f *fixture) []repositories.Campsite {
+	t.Helper()
+
+	loopA, loopB := "A", "B"
+	standard, tent := "STANDARD", "TENT ONLY"
+	campsites, err := f.server.Repository.UpsertCampsites(context.Background(), []repositories.UpsertCampsitePayload{
+		{Name: "001", CampsiteId: "70", FacilityId: f.upper.Id, Loop: &loopA, SiteType: &standard},
+		{Name: "002", CampsiteId: "71", FacilityId: f.upper.Id, Loop: &loopA, SiteType: &standard},
+		{Name: "077", CampsiteId: "146", FacilityId: f.upper.Id, Loop: &loopB, SiteType: &tent},
+	})
+	if err != nil {
+		t.Fatalf("UpsertCampsites: %v", err)
+	}
+
+	return campsites
+}
+
+// assertError checks the v2 rendering of an error on the unversioned path,
+// or on path itself when it is already versioned.
+func assertError(t *testing.T, f *fixture, method, path string, body interface{}, status int, code errs.Code, message string) {
+	t.Helper()
+
+	if !strings.HasPrefix(path, "/v2/") {
+		path = "/v2" + path
+	}
+
+	res, raw := f.do(t, method, path, body)
+	if res.StatusCode != status {
+		t.Errorf("%s %s = %d %s, want %d", method, path, res.StatusCode, raw, status)
+		return
+	}
+
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &wrapper); err != nil {
+		t.Fatalf("failed to decode %s: %v", raw, err)
+	}
+	assertKeys(t, wrapper["error"], "code", "message", "details", "requestId")
+	got := decode[middlewares.ErrorResponse](t, raw).Error
+	if got.Code != code || got.Message != message || got.RequestId != res.Header.Get(middlewares.RequestIdHeader) {
+		t.Errorf("%s %s error = %+v, want %s %q with the request id", method, path, got, code, message)
+	}
+}
+
+// assertLegacyError checks the v1 rendering of an error on the unversioned
+// path: the bare message string handlers answered with before errors were
+// typed.
+func assertLegacyError(t *testing.T, f *fixture, method, path string, body interface{}, status int, message string) {
+	t.Helper()
+
+	res, raw := f.do(t, method, "/v1"+path, body)
+	if res.StatusCode != status || decode[string](t, raw) != message {
+		t.Errorf("%s /v1%s = %d %s, want %d %q", method, path, res.StatusCode, raw, status, message)
+	}
+}
+
+func assertKeys(t *testing.T, raw interface{}, want ...string) {
+	t.Helper()
+
+	var object map[string]json.RawMessage
+	switch raw := raw.(type) {
+	case json.RawMessage:
+		if err := json.Unmarshal(raw, &object); err != nil {
+			t.Fatalf("failed to decode %s: %v", raw, err)
+		}
+	case map[string]json.RawMessage:
+		object = raw
+	}
+
+	got := make([]string, 0, len(object))
+	for key := range object {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+}
+
+func assertNames(t *testing.T, data []json.RawMessage, want ...string) {
+	t.Helper()
+
+	got := make([]string, len(data))
+	for idx, item := range data {
+		got[idx] = decode[struct {
+			Name string `json:"name"`
+		}](t, item).Name
+	}
+	if len(want) == 0 {
+		want = []string{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("names = %v, want %v", got, want)
+	}
+}
+
+func assertIds(t *testing.T, data []json.RawMessage, want ...int) {
+	t.Helper()
+
+	got := make([]int, len(data))
+	for idx, item := range data {
+		got[idx] = decode[struct {
+			Id int `json:"id"`
+		}](t, item).Id
+	}
+	if len(want) == 0 {
+		want = []int{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ids = %v, want %v", got, want)
+	}
+}
+
+func decode[T any](t *testing.T, raw json.RawMessage) T {
+	t.Helper()
+
+	var out T
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("failed to decode %s into %T: %v", raw, out, err)
+	}
+	return out
+}
+
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	t.Helper()
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to encode %v: %v", v, err)
+	}
+	return raw
+}
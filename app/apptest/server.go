@@ -0,0 +1,77 @@
+// Package apptest serves the HTTP API over httptest on top of the in-memory
+// repository, so handlers can be exercised end to end without a database.
+package apptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/katakeda/lantrn-api-go/app"
+	"github.com/katakeda/lantrn-api-go/repositories"
+	"github.com/katakeda/lantrn-api-go/services"
+)
+
+type Server struct {
+	*httptest.Server
+
+	// Repository is what the handlers read and write. Seed it directly to
+	// set up data the API has no endpoint for, such as facilities.
+	Repository *repositories.MemoryRepository
+}
+
+// NewServer starts a server with an empty repository. Callers must Close it.
+func NewServer(opts ...repositories.Option) (*Server, error) {
+	gin.SetMode(gin.TestMode)
+
+	repo, err := repositories.NewMemoryRepository(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize repository | %w", err)
+	}
+
+	svc, err := services.NewService(repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize service | %w", err)
+	}
+
+	return &Server{
+		Server:     httptest.NewServer(app.NewRouter(svc)),
+		Repository: repo,
+	}, nil
+}
+
+// Do sends a request to path with body encoded as JSON when it isn't nil, and
+// decodes the response into out when it isn't nil.
+func (s *Server) Do(method, path string, body, out interface{}) (*http.Response, error) {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return nil, fmt.Errorf("failed to encode body | %w", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, &payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request | %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := s.Client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request | %w", err)
+	}
+	defer res.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return res, fmt.Errorf("failed to decode response | %w", err)
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/health"
+	"github.com/katakeda/lantrn-api-go/migrations"
+)
+
+// readinessChecks reports the service ready while the database is reachable
+// and migrated at least as far as this build expects, and, when polling, the
+// scheduler keeps beating.
+func (app *App) readinessChecks() ([]health.Check, error) {
+	latest, err := migrations.Latest()
+	if err != nil {
+		return nil, err
+	}
+
+	checks := []health.Check{
+		{Name: "database", Check: app.repo.Ping},
+		{Name: "migrations", Check: func(ctx context.Context) error {
+			version, err := app.repo.MigrationVersion(ctx)
+			if err != nil {
+				return err
+			}
+			// A newer release may have migrated ahead during a deploy, which
+			// this one must tolerate.
+			if version < latest {
+				return fmt.Errorf("database is at migration %d, want %d", version, latest)
+			}
+			return nil
+		}},
+	}
+
+	if app.scheduler != nil {
+		checks = append(checks, health.Check{Name: "worker", Check: func(ctx context.Context) error {
+			if since := time.Since(app.scheduler.Heartbeat()); since > app.config.WorkerHeartbeatTimeout {
+				return fmt.Errorf("scheduler last beat %s ago", since.Round(time.Second))
+			}
+			return nil
+		}})
+	}
+
+	return checks, nil
+}
@@ -0,0 +1,106 @@
+package availability
+
+import (
+	"sort"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+type EventType string
+
+const (
+	EventNewlyAvailable EventType = "newly_available"
+	EventNewlyBooked    EventType = "newly_booked"
+	EventStatusChanged  EventType = "status_changed"
+	// EventFirstSeen records the status of a campsite and date seen for the
+	// first time. It is a baseline for later diffs, not a change.
+	EventFirstSeen EventType = "first_seen"
+)
+
+type ChangeEvent struct {
+	Type           EventType `json:"type"`
+	FacilityId     int       `json:"facilityId"`
+	CampsiteId     *int      `json:"campsiteId"`
+	Date           string    `json:"date"`
+	PreviousStatus string    `json:"previousStatus"`
+	Status         string    `json:"status"`
+	ObservedAt     time.Time `json:"observedAt"`
+}
+
+type snapshotKey struct {
+	campsiteId int
+	hasSite    bool
+	date       string
+}
+
+func keyOf(snapshot repositories.AvailabilitySnapshot) snapshotKey {
+	key := snapshotKey{date: snapshot.Date}
+	if snapshot.CampsiteId != nil {
+		key.campsiteId = *snapshot.CampsiteId
+		key.hasSite = true
+	}
+	return key
+}
+
+// Diff compares the latest stored snapshot of every campsite and date with a
+// fresh observation and returns one event per status change. A campsite and
+// date that has never been seen before is reported as first seen rather than
+// newly available, since there is no telling when it opened, so the first poll
+// of a facility doesn't announce everything that is already open. Keys missing
+// from current are left alone since the upstream window may have moved.
+func Diff(previous, current []repositories.AvailabilitySnapshot) []ChangeEvent {
+	previousMap := make(map[snapshotKey]repositories.AvailabilitySnapshot, len(previous))
+	for _, snapshot := range previous {
+		previousMap[keyOf(snapshot)] = snapshot
+	}
+
+	events := []ChangeEvent{}
+	for _, snapshot := range current {
+		prev, seen := previousMap[keyOf(snapshot)]
+		if seen && prev.Status == snapshot.Status {
+			continue
+		}
+
+		event := ChangeEvent{
+			Type:       EventFirstSeen,
+			FacilityId: snapshot.FacilityId,
+			CampsiteId: snapshot.CampsiteId,
+			Date:       snapshot.Date,
+			Status:     snapshot.Status,
+			ObservedAt: snapshot.ObservedAt,
+		}
+		if seen {
+			event.Type = classify(prev.Status, snapshot.Status)
+			event.PreviousStatus = prev.Status
+		}
+		events = append(events, event)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Date != events[j].Date {
+			return events[i].Date < events[j].Date
+		}
+		return campsiteIdOf(events[i]) < campsiteIdOf(events[j])
+	})
+
+	return events
+}
+
+func classify(previousStatus, status string) EventType {
+	switch {
+	case status == repositories.AvailabilityStatusAvailable:
+		return EventNewlyAvailable
+	case previousStatus == repositories.AvailabilityStatusAvailable:
+		return EventNewlyBooked
+	default:
+		return EventStatusChanged
+	}
+}
+
+func campsiteIdOf(event ChangeEvent) int {
+	if event.CampsiteId == nil {
+		return 0
+	}
+	return *event.CampsiteId
+}
@@ -0,0 +1,109 @@
+package availability
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+func TestDiff(t *testing.T) {
+	observedAt := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	site := func(id int) *int { return &id }
+	snapshot := func(campsiteId *int, date, status string) repositories.AvailabilitySnapshot {
+		return repositories.AvailabilitySnapshot{FacilityId: 1, CampsiteId: campsiteId, Date: date, Status: status, ObservedAt: observedAt}
+	}
+	event := func(eventType EventType, campsiteId *int, date, previousStatus, status string) ChangeEvent {
+		return ChangeEvent{Type: eventType, FacilityId: 1, CampsiteId: campsiteId, Date: date, PreviousStatus: previousStatus, Status: status, ObservedAt: observedAt}
+	}
+
+	const (
+		available    = repositories.AvailabilityStatusAvailable
+		reserved     = repositories.AvailabilityStatusReserved
+		notAvailable = repositories.AvailabilityStatusNotAvailable
+	)
+
+	tests := []struct {
+		name     string
+		previous []repositories.AvailabilitySnapshot
+		current  []repositories.AvailabilitySnapshot
+		want     []ChangeEvent
+	}{
+		{
+			name: "nothing observed",
+			want: []ChangeEvent{},
+		},
+		{
+			name:    "first sighting is a baseline even when available",
+			current: []repositories.AvailabilitySnapshot{snapshot(site(1), "2026-11-01", available), snapshot(site(2), "2026-11-01", reserved)},
+			want: []ChangeEvent{
+				event(EventFirstSeen, site(1), "2026-11-01", "", available),
+				event(EventFirstSeen, site(2), "2026-11-01", "", reserved),
+			},
+		},
+		{
+			name:     "unchanged status",
+			previous: []repositories.AvailabilitySnapshot{snapshot(site(1), "2026-11-01", available)},
+			current:  []repositories.AvailabilitySnapshot{snapshot(site(1), "2026-11-01", available)},
+			want:     []ChangeEvent{},
+		},
+		{
+			name:     "reserved site opens",
+			previous: []repositories.AvailabilitySnapshot{snapshot(site(1), "2026-11-01", reserved)},
+			current:  []repositories.AvailabilitySnapshot{snapshot(site(1), "2026-11-01", available)},
+			want:     []ChangeEvent{event(EventNewlyAvailable, site(1), "2026-11-01", reserved, available)},
+		},
+		{
+			name:     "available site is booked",
+			previous: []repositories.AvailabilitySnapshot{snapshot(site(1), "2026-11-01", available)},
+			current:  []repositories.AvailabilitySnapshot{snapshot(site(1), "2026-11-01", reserved)},
+			want:     []ChangeEvent{event(EventNewlyBooked, site(1), "2026-11-01", available, reserved)},
+		},
+		{
+			name:     "change between unavailable statuses",
+			previous: []repositories.AvailabilitySnapshot{snapshot(site(1), "2026-11-01", reserved)},
+			current:  []repositories.AvailabilitySnapshot{snapshot(site(1), "2026-11-01", notAvailable)},
+			want:     []ChangeEvent{event(EventStatusChanged, site(1), "2026-11-01", reserved, notAvailable)},
+		},
+		{
+			name:     "facility wide snapshot without campsite",
+			previous: []repositories.AvailabilitySnapshot{snapshot(nil, "2026-11-01", notAvailable)},
+			current:  []repositories.AvailabilitySnapshot{snapshot(nil, "2026-11-01", available)},
+			want:     []ChangeEvent{event(EventNewlyAvailable, nil, "2026-11-01", notAvailable, available)},
+		},
+		{
+			name:     "keys missing from current are left alone",
+			previous: []repositories.AvailabilitySnapshot{snapshot(site(1), "2026-11-01", available), snapshot(site(1), "2026-11-02", reserved)},
+			current:  []repositories.AvailabilitySnapshot{snapshot(site(1), "2026-11-02", reserved)},
+			want:     []ChangeEvent{},
+		},
+		{
+			name: "events ordered by date then campsite",
+			previous: []repositories.AvailabilitySnapshot{
+				snapshot(site(2), "2026-11-02", reserved),
+				snapshot(site(1), "2026-11-02", reserved),
+				snapshot(site(3), "2026-11-01", available),
+			},
+			current: []repositories.AvailabilitySnapshot{
+				snapshot(site(2), "2026-11-02", available),
+				snapshot(site(1), "2026-11-02", available),
+				snapshot(site(3), "2026-11-01", reserved),
+			},
+			want: []ChangeEvent{
+				event(EventNewlyBooked, site(3), "2026-11-01", available, reserved),
+				event(EventNewlyAvailable, site(1), "2026-11-02", reserved, available),
+				event(EventNewlyAvailable, site(2), "2026-11-02", reserved, available),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(tt.previous, tt.current)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
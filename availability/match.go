@@ -0,0 +1,75 @@
+package availability
+
+import (
+	"strings"
+
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+type Match struct {
+	Subscription repositories.Subscription
+	Events       []ChangeEvent
+}
+
+// MatchSubscriptions pairs subscriptions with the openings they asked for.
+// Only newly available events are considered, so an opening that stays open
+// across polls is reported once. Events for the same subscription are grouped
+// so several sites opening at once results in a single match.
+func MatchSubscriptions(events []ChangeEvent, subscriptions []repositories.Subscription, campsites map[int]repositories.Campsite) []Match {
+	matches := []Match{}
+	for _, subscription := range subscriptions {
+		var matched []ChangeEvent
+		for _, event := range events {
+			if event.Type != EventNewlyAvailable {
+				continue
+			}
+			if event.FacilityId != subscription.FacilityId || event.Date != subscription.TargetDate {
+				continue
+			}
+			if !matchesCampsite(subscription, event.CampsiteId, campsites) {
+				continue
+			}
+			matched = append(matched, event)
+		}
+
+		if len(matched) > 0 {
+			matches = append(matches, Match{
+				Subscription: subscription,
+				Events:       matched,
+			})
+		}
+	}
+
+	return matches
+}
+
+func matchesCampsite(subscription repositories.Subscription, campsiteId *int, campsites map[int]repositories.Campsite) bool {
+	if len(subscription.CampsiteIds) <= 0 && subscription.SiteType == nil {
+		return true
+	}
+	if campsiteId == nil {
+		return false
+	}
+
+	if len(subscription.CampsiteIds) > 0 {
+		found := false
+		for _, id := range subscription.CampsiteIds {
+			if id == *campsiteId {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if subscription.SiteType != nil {
+		campsite, ok := campsites[*campsiteId]
+		if !ok || campsite.SiteType == nil || !strings.EqualFold(*campsite.SiteType, *subscription.SiteType) {
+			return false
+		}
+	}
+
+	return true
+}
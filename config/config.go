@@ -0,0 +1,423 @@
+// Package config loads the service configuration. Every setting has a
+// default, which a dotenv file, the environment and command line flags
+// override in that order.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const defaultFile = ".env"
+
+type Config struct {
+	ListenAddr string
+	// MetricsAddr serves Prometheus metrics apart from the API, so they
+	// aren't public.
+	MetricsAddr string
+	LogLevel    string
+
+	HTTPReadTimeout  time.Duration
+	HTTPWriteTimeout time.Duration
+	HTTPIdleTimeout  time.Duration
+	// ShutdownTimeout bounds draining requests and stopping workers, and must
+	// stay below the kill_timeout in fly.toml.
+	ShutdownTimeout time.Duration
+
+	DatabaseURL            string
+	DatabaseReadURL        string
+	DatabaseMaxConns       int
+	DatabaseMinConns       int
+	DatabaseConnectTimeout time.Duration
+
+	CursorSecret   string
+	DefaultPerPage int
+	MaxPerPage     int
+	SearchRadius   float64
+
+	PollerEnabled   bool
+	RecGovBaseURL   string
+	RecGovRateLimit time.Duration
+	// WorkerHeartbeatTimeout is how long the scheduler may go without a
+	// heartbeat before the service reports it isn't ready.
+	WorkerHeartbeatTimeout time.Duration
+
+	// The Poller settings are those of poller.SchedulerConfig, which main
+	// maps them to.
+	PollerTickInterval           time.Duration
+	PollerMinInterval            time.Duration
+	PollerMaxInterval            time.Duration
+	PollerSubscriptionWeight     float64
+	PollerSubscriptionSaturation int
+	PollerProximityWeight        float64
+	PollerProximityHorizon       time.Duration
+	PollerChurnWeight            float64
+	PollerChurnSaturation        float64
+	PollerChurnDecay             float64
+	PollerMaxConcurrentPerHost   int
+	PollerMaxWindow              time.Duration
+	PollerBackoffBase            time.Duration
+	PollerBackoffMax             time.Duration
+	PollerBackoffJitter          float64
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// AuthKeys are the API keys accepted from clients.
+	AuthKeys []string
+}
+
+// redaction says how a secret setting is shown when the config is printed.
+type redaction int
+
+const (
+	public redaction = iota
+	secret
+	secretURL
+)
+
+// setting ties an environment variable, and the flag derived from its name,
+// to a field of Config.
+type setting struct {
+	key    string
+	usage  string
+	value  interface{}
+	redact redaction
+}
+
+func Default() *Config {
+	return &Config{
+		ListenAddr:             ":8080",
+		MetricsAddr:            ":9091",
+		LogLevel:               "info",
+		HTTPReadTimeout:        10 * time.Second,
+		HTTPWriteTimeout:       30 * time.Second,
+		HTTPIdleTimeout:        60 * time.Second,
+		ShutdownTimeout:        4 * time.Second,
+		DatabaseMaxConns:       10,
+		DatabaseMinConns:       0,
+		DatabaseConnectTimeout: 5 * time.Second,
+		DefaultPerPage:         25,
+		MaxPerPage:             100,
+		SearchRadius:           80000,
+		RecGovRateLimit:        time.Second,
+		WorkerHeartbeatTimeout: 5 * time.Minute,
+
+		PollerTickInterval:           15 * time.Second,
+		PollerMinInterval:            2 * time.Minute,
+		PollerMaxInterval:            30 * time.Minute,
+		PollerSubscriptionWeight:     0.5,
+		PollerSubscriptionSaturation: 20,
+		PollerProximityWeight:        0.3,
+		PollerProximityHorizon:       14 * 24 * time.Hour,
+		PollerChurnWeight:            0.2,
+		PollerChurnSaturation:        10,
+		PollerChurnDecay:             0.5,
+		PollerMaxConcurrentPerHost:   2,
+		PollerMaxWindow:              180 * 24 * time.Hour,
+		PollerBackoffBase:            time.Minute,
+		PollerBackoffMax:             time.Hour,
+		PollerBackoffJitter:          0.2,
+
+		SMTPPort: 587,
+	}
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"LISTEN_ADDR", "address the HTTP server listens on", &c.ListenAddr, public},
+		{"METRICS_ADDR", "address Prometheus metrics are served on, apart from the API", &c.MetricsAddr, public},
+		{"LOG_LEVEL", "least severe level logged, one of debug, info, warn and error", &c.LogLevel, public},
+		{"HTTP_READ_TIMEOUT", "how long to wait for a request to be read", &c.HTTPReadTimeout, public},
+		{"HTTP_WRITE_TIMEOUT", "how long a response may take to be written", &c.HTTPWriteTimeout, public},
+		{"HTTP_IDLE_TIMEOUT", "how long idle keep-alive connections are kept open", &c.HTTPIdleTimeout, public},
+		{"SHUTDOWN_TIMEOUT", "how long to drain requests and stop workers on shutdown", &c.ShutdownTimeout, public},
+		{"DATABASE_URL", "Postgres connection URL", &c.DatabaseURL, secretURL},
+		{"DATABASE_READ_URL", "Postgres read replica connection URL", &c.DatabaseReadURL, secretURL},
+		{"DATABASE_MAX_CONNS", "maximum connections per pool", &c.DatabaseMaxConns, public},
+		{"DATABASE_MIN_CONNS", "connections kept open per pool", &c.DatabaseMinConns, public},
+		{"DATABASE_CONNECT_TIMEOUT", "how long to wait for a database connection", &c.DatabaseConnectTimeout, public},
+		{"CURSOR_SECRET", "key pagination cursors are signed with", &c.CursorSecret, secret},
+		{"DEFAULT_PER_PAGE", "page size when clients don't ask for one", &c.DefaultPerPage, public},
+		{"MAX_PER_PAGE", "largest page size clients can ask for", &c.MaxPerPage, public},
+		{"SEARCH_RADIUS", "distance in meters facilities are searched within", &c.SearchRadius, public},
+		{"POLLER_ENABLED", "poll recreation.gov for availability", &c.PollerEnabled, public},
+		{"RECGOV_BASE_URL", "recreation.gov base URL", &c.RecGovBaseURL, public},
+		{"RECGOV_RATE_LIMIT", "minimum time between recreation.gov requests", &c.RecGovRateLimit, public},
+		{"POLLER_TICK_INTERVAL", "how often the scheduler looks for facilities due for a check", &c.PollerTickInterval, public},
+		{"POLLER_MIN_INTERVAL", "shortest time between checks of a facility", &c.PollerMinInterval, public},
+		{"POLLER_MAX_INTERVAL", "longest time between checks of a facility", &c.PollerMaxInterval, public},
+		{"POLLER_SUBSCRIPTION_WEIGHT", "priority weight of active subscriptions", &c.PollerSubscriptionWeight, public},
+		{"POLLER_SUBSCRIPTION_SATURATION", "active subscriptions at which their priority stops growing", &c.PollerSubscriptionSaturation, public},
+		{"POLLER_PROXIMITY_WEIGHT", "priority weight of the nearest target date", &c.PollerProximityWeight, public},
+		{"POLLER_PROXIMITY_HORIZON", "how far ahead a target date starts raising priority", &c.PollerProximityHorizon, public},
+		{"POLLER_CHURN_WEIGHT", "priority weight of recent availability changes", &c.PollerChurnWeight, public},
+		{"POLLER_CHURN_SATURATION", "recent changes at which their priority stops growing", &c.PollerChurnSaturation, public},
+		{"POLLER_CHURN_DECAY", "share of past changes carried into each check, between 0 and 1", &c.PollerChurnDecay, public},
+		{"POLLER_MAX_CONCURRENT_PER_HOST", "concurrent checks per upstream host", &c.PollerMaxConcurrentPerHost, public},
+		{"POLLER_MAX_WINDOW", "furthest ahead availability is checked", &c.PollerMaxWindow, public},
+		{"POLLER_BACKOFF_BASE", "first backoff after a failed check", &c.PollerBackoffBase, public},
+		{"POLLER_BACKOFF_MAX", "longest backoff after failed checks", &c.PollerBackoffMax, public},
+		{"POLLER_BACKOFF_JITTER", "fraction backoffs are randomly varied by, between 0 and 1", &c.PollerBackoffJitter, public},
+		{"WORKER_HEARTBEAT_TIMEOUT", "how long the scheduler may go quiet before the service isn't ready", &c.WorkerHeartbeatTimeout, public},
+		{"SMTP_HOST", "SMTP server host", &c.SMTPHost, public},
+		{"SMTP_PORT", "SMTP server port", &c.SMTPPort, public},
+		{"SMTP_USERNAME", "SMTP username", &c.SMTPUsername, public},
+		{"SMTP_PASSWORD", "SMTP password", &c.SMTPPassword, secret},
+		{"SMTP_FROM", "address notifications are sent from", &c.SMTPFrom, public},
+		{"AUTH_KEYS", "comma separated API keys accepted from clients", &c.AuthKeys, secret},
+	}
+}
+
+// Load reads the configuration. The dotenv file is .env unless -config or
+// CONFIG_FILE names another one, which must then exist.
+func Load(args []string) (*Config, error) {
+	c := Default()
+
+	file, required := configFile(args)
+	if err := godotenv.Load(file); err != nil && (required || !errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("failed to load config file %s | %w", file, err)
+	}
+
+	for _, s := range c.settings() {
+		value, ok := os.LookupEnv(s.key)
+		if !ok {
+			continue
+		}
+		if err := set(s.value, value); err != nil {
+			return nil, fmt.Errorf("invalid %s | %w", s.key, err)
+		}
+	}
+
+	flags := flag.NewFlagSet("lantrn-api-go", flag.ContinueOnError)
+	flags.String("config", file, "dotenv file to load settings from")
+	for _, s := range c.settings() {
+		flags.Var(flagValue{s.value}, flagName(s.key), fmt.Sprintf("%s (%s)", s.usage, s.key))
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Config) Validate() error {
+	switch {
+	case c.ListenAddr == "":
+		return fmt.Errorf("LISTEN_ADDR is required")
+	case c.MetricsAddr == "" || c.MetricsAddr == c.ListenAddr:
+		return fmt.Errorf("METRICS_ADDR is required and must differ from LISTEN_ADDR")
+	case c.HTTPReadTimeout <= 0 || c.HTTPWriteTimeout <= 0 || c.HTTPIdleTimeout <= 0:
+		return fmt.Errorf("HTTP_READ_TIMEOUT, HTTP_WRITE_TIMEOUT and HTTP_IDLE_TIMEOUT must be positive")
+	case c.ShutdownTimeout <= 0:
+		return fmt.Errorf("SHUTDOWN_TIMEOUT must be positive")
+	case c.DatabaseURL == "":
+		return fmt.Errorf("DATABASE_URL is required")
+	case c.DatabaseMaxConns <= 0:
+		return fmt.Errorf("DATABASE_MAX_CONNS must be positive")
+	case c.DatabaseMinConns < 0 || c.DatabaseMinConns > c.DatabaseMaxConns:
+		return fmt.Errorf("DATABASE_MIN_CONNS must be between 0 and DATABASE_MAX_CONNS")
+	case c.DatabaseConnectTimeout <= 0:
+		return fmt.Errorf("DATABASE_CONNECT_TIMEOUT must be positive")
+	case c.CursorSecret != "" && len(c.CursorSecret) < 16:
+		return fmt.Errorf("CURSOR_SECRET must be at least 16 characters")
+	case c.MaxPerPage <= 0:
+		return fmt.Errorf("MAX_PER_PAGE must be positive")
+	case c.DefaultPerPage <= 0 || c.DefaultPerPage > c.MaxPerPage:
+		return fmt.Errorf("DEFAULT_PER_PAGE must be between 1 and MAX_PER_PAGE")
+	case c.SearchRadius <= 0:
+		return fmt.Errorf("SEARCH_RADIUS must be positive")
+	case c.RecGovRateLimit < 0:
+		return fmt.Errorf("RECGOV_RATE_LIMIT must not be negative")
+	case c.WorkerHeartbeatTimeout <= 0:
+		return fmt.Errorf("WORKER_HEARTBEAT_TIMEOUT must be positive")
+	case c.SMTPHost != "" && (c.SMTPPort <= 0 || c.SMTPPort > 65535):
+		return fmt.Errorf("SMTP_PORT must be a valid port")
+	case c.SMTPHost != "" && c.SMTPFrom == "":
+		return fmt.Errorf("SMTP_FROM is required when SMTP_HOST is set")
+	}
+
+	if _, err := c.Level(); err != nil {
+		return err
+	}
+
+	if c.RecGovBaseURL != "" {
+		if u, err := url.Parse(c.RecGovBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("RECGOV_BASE_URL must be an absolute URL")
+		}
+	}
+
+	return nil
+}
+
+// Level parses LogLevel.
+func (c *Config) Level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, fmt.Errorf("LOG_LEVEL must be one of debug, info, warn and error")
+	}
+	return level, nil
+}
+
+// String lists every setting with secrets redacted.
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, s := range c.settings() {
+		fmt.Fprintf(&b, "%s=%s\n", s.key, s.redacted())
+	}
+	return b.String()
+}
+
+// LogValue logs every setting with secrets redacted, for logging at startup.
+func (c *Config) LogValue() slog.Value {
+	settings := c.settings()
+	attrs := make([]slog.Attr, len(settings))
+	for idx, s := range settings {
+		attrs[idx] = slog.String(s.key, s.redacted())
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (s setting) redacted() string {
+	value := flagValue{s.value}.String()
+	if value == "" {
+		return value
+	}
+
+	switch s.redact {
+	case secret:
+		return "[redacted]"
+	case secretURL:
+		return redactURL(value)
+	}
+	return value
+}
+
+// configFile finds the dotenv file to load, and whether it was asked for
+// rather than being the default.
+func configFile(args []string) (file string, required bool) {
+	for idx, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if strings.HasPrefix(name, "config=") {
+			return strings.TrimPrefix(name, "config="), true
+		}
+		if name == "config" && idx+1 < len(args) {
+			return args[idx+1], true
+		}
+	}
+
+	if file, ok := os.LookupEnv("CONFIG_FILE"); ok && file != "" {
+		return file, true
+	}
+
+	return defaultFile, false
+}
+
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+func redactURL(value string) string {
+	// Keyword/value connection strings can't be picked apart safely.
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" {
+		return "[redacted]"
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "redacted")
+	}
+	return u.String()
+}
+
+// flagValue reads and writes the field a setting points at.
+type flagValue struct {
+	value interface{}
+}
+
+func (f flagValue) String() string {
+	switch v := f.value.(type) {
+	case *string:
+		return *v
+	case *int:
+		return strconv.Itoa(*v)
+	case *float64:
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	case *bool:
+		return strconv.FormatBool(*v)
+	case *time.Duration:
+		return v.String()
+	case *[]string:
+		return strings.Join(*v, ",")
+	}
+	return ""
+}
+
+// IsBoolFlag lets boolean settings be given as a bare flag.
+func (f flagValue) IsBoolFlag() bool {
+	_, ok := f.value.(*bool)
+	return ok
+}
+
+func (f flagValue) Set(value string) error {
+	return set(f.value, value)
+}
+
+func set(target interface{}, value string) error {
+	switch v := target.(type) {
+	case *string:
+		*v = value
+	case *int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*v = number
+	case *float64:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*v = number
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		*v = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 5m", value)
+		}
+		*v = d
+	case *[]string:
+		*v = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*v = append(*v, item)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported setting type %T", target)
+	}
+	return nil
+}
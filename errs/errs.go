@@ -0,0 +1,110 @@
+// Package errs holds the typed errors repositories and services return so the
+// HTTP layer can answer with the right status and a stable error code.
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type Code string
+
+const (
+	CodeNotFound        Code = "not_found"
+	CodeInvalidArgument Code = "invalid_argument"
+	CodeValidation      Code = "validation_failed"
+	CodeConflict        Code = "conflict"
+	CodeUnauthorized    Code = "unauthorized"
+	CodeInternal        Code = "internal"
+)
+
+// FieldError describes why a single payload field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Code    Code
+	Message string
+	Details interface{}
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s | %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Status() int {
+	switch e.Code {
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeInvalidArgument:
+		return http.StatusBadRequest
+	case CodeValidation:
+		return http.StatusUnprocessableEntity
+	case CodeConflict:
+		return http.StatusConflict
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// WithDetails returns a copy of e carrying details, e.g. the offending field.
+func (e *Error) WithDetails(details interface{}) *Error {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+func New(code Code, message string, err error) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+		Err:     err,
+	}
+}
+
+func NotFound(message string, err error) *Error {
+	return New(CodeNotFound, message, err)
+}
+
+func InvalidArgument(message string, err error) *Error {
+	return New(CodeInvalidArgument, message, err)
+}
+
+func Validation(message string, fields []FieldError) *Error {
+	return New(CodeValidation, message, nil).WithDetails(fields)
+}
+
+func Conflict(message string, err error) *Error {
+	return New(CodeConflict, message, err)
+}
+
+func Unauthorized(message string, err error) *Error {
+	return New(CodeUnauthorized, message, err)
+}
+
+func Internal(message string, err error) *Error {
+	return New(CodeInternal, message, err)
+}
+
+// From returns the first *Error in err's chain, or wraps err as an internal
+// error with message when there is none.
+func From(err error, message string) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(message, err)
+}
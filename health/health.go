@@ -0,0 +1,83 @@
+// Package health serves the liveness, readiness and build info endpoints that
+// Fly and operators poll. They sit outside the versioned API.
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Commit and BuildTime are set when building, with
+//
+//	-ldflags "-X github.com/katakeda/lantrn-api-go/health.Commit=... -X github.com/katakeda/lantrn-api-go/health.BuildTime=..."
+var (
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// checkTimeout bounds all checks of one readiness probe together, below the
+// timeout of the HTTP check in fly.toml.
+const checkTimeout = 2 * time.Second
+
+// Check is a dependency the service can't serve requests without.
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type statusResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type versionResponse struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Healthz answers as long as the process can serve HTTP at all.
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// Readyz runs every check and answers 503 when any of them fails, so traffic
+// is routed elsewhere until it recovers. Failures are logged rather than
+// returned, since their errors can name hosts and users.
+func Readyz(checks ...Check) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), checkTimeout)
+		defer cancel()
+
+		response := statusResponse{
+			Status: "ok",
+			Checks: make(map[string]string),
+		}
+		status := http.StatusOK
+		for _, check := range checks {
+			if err := check.Check(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed readiness check", "check", check.Name, "error", err)
+				response.Checks[check.Name] = "failed"
+				response.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			response.Checks[check.Name] = "ok"
+		}
+
+		c.JSON(status, response)
+	}
+}
+
+func Version(c *gin.Context) {
+	c.JSON(http.StatusOK, versionResponse{
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	})
+}
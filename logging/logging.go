@@ -0,0 +1,91 @@
+// Package logging writes structured JSON logs. Records logged with a context
+// carry the ID of the request it belongs to, and emails and tokens are
+// redacted from every record.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const RequestIdKey = "request_id"
+
+type requestIdKey struct{}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// Subscription tokens are exactly 64 lowercase hex characters. Request IDs,
+	// trace IDs and most hashes are of other lengths, so they are left alone.
+	tokenPattern = regexp.MustCompile(`\b[0-9a-f]{64}\b`)
+
+	// sensitiveKeys are attributes redacted whatever their value.
+	sensitiveKeys = map[string]bool{
+		"authorization": true,
+		"email":         true,
+		"password":      true,
+		"token":         true,
+	}
+)
+
+// New returns a logger writing JSON records of level and above to w.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})})
+}
+
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId returns the ID of the request ctx belongs to, if any.
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// Redact masks the emails and tokens in s.
+func Redact(s string) string {
+	s = emailPattern.ReplaceAllString(s, "[email]")
+	return tokenPattern.ReplaceAllString(s, "[token]")
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "[redacted]")
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(Redact(err.Error()))
+		}
+	}
+	return a
+}
+
+// contextHandler adds the request ID of the context a record is logged with.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestId(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIdKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
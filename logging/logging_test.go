@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var token = strings.Repeat("0123456789abcdef", 4)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "email", in: "sent to jane.doe+camp@example.co.uk", want: "sent to [email]"},
+		{name: "emails", in: "a@example.com, b@example.org", want: "[email], [email]"},
+		{name: "token", in: "token " + token, want: "token [token]"},
+		{name: "token in a query", in: "/v1/subscription_tokens?token=" + token + "&page=1", want: "/v1/subscription_tokens?token=[token]&page=1"},
+		{name: "request id", in: "request 9f86d081884c7d65", want: "request 9f86d081884c7d65"},
+		{name: "trace id", in: "trace 4bf92f3577b34da6a3ce929d0e0e4736", want: "trace 4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "sha1", in: "commit 2fd4e1c67a2d28fced849ee1bb76e7391b93eb12", want: "commit 2fd4e1c67a2d28fced849ee1bb76e7391b93eb12"},
+		{name: "longer hex", in: "digest " + token + token, want: "digest " + token + token},
+		{name: "uppercase hex", in: "id " + strings.ToUpper(token), want: "id " + strings.ToUpper(token)},
+		{name: "nothing to redact", in: "Handled request", want: "Handled request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerRedacts(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+		want map[string]interface{}
+	}{
+		{
+			name: "message",
+			log:  func(logger *slog.Logger) { logger.Info("Notified jane@example.com") },
+			want: map[string]interface{}{"msg": "Notified [email]"},
+		},
+		{
+			name: "string values",
+			log:  func(logger *slog.Logger) { logger.Info("Created", "path", "/tokens?token="+token) },
+			want: map[string]interface{}{"path": "/tokens?token=[token]"},
+		},
+		{
+			name: "errors",
+			log: func(logger *slog.Logger) {
+				logger.Error("Failed", "error", errors.New("failed to notify jane@example.com"))
+			},
+			want: map[string]interface{}{"error": "failed to notify [email]"},
+		},
+		{
+			name: "sensitive keys",
+			log: func(logger *slog.Logger) {
+				logger.Info("Request", "Authorization", "Bearer abc", "email", "not an address", "password", 42, "token", "short")
+			},
+			want: map[string]interface{}{"Authorization": "[redacted]", "email": "[redacted]", "password": "[redacted]", "token": "[redacted]"},
+		},
+		{
+			name: "nested groups",
+			log: func(logger *slog.Logger) {
+				logger.Info("Subscribed", slog.Group("subscriber",
+					slog.String("email", "jane@example.com"),
+					slog.String("note", "also jane@example.com"),
+					slog.Group("auth", slog.String("password", "hunter2"), slog.Int("id", 7)),
+				))
+			},
+			want: map[string]interface{}{
+				"subscriber": map[string]interface{}{
+					"email": "[redacted]",
+					"note":  "also [email]",
+					"auth":  map[string]interface{}{"password": "[redacted]", "id": float64(7)},
+				},
+			},
+		},
+		{
+			name: "attributes of a group logger",
+			log: func(logger *slog.Logger) {
+				logger.WithGroup("poller").With("token", token).Info("Checked", "facility", "232447")
+			},
+			want: map[string]interface{}{
+				"poller": map[string]interface{}{"token": "[redacted]", "facility": "232447"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			tt.log(New(&out, slog.LevelInfo))
+
+			var record map[string]interface{}
+			if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+				t.Fatalf("failed to decode %s: %v", out.Bytes(), err)
+			}
+			for key, want := range tt.want {
+				if got := record[key]; !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %#v, want %#v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoggerAddsRequestId(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, slog.LevelInfo)
+
+	logger.InfoContext(WithRequestId(context.Background(), "req-1"), "With")
+	logger.InfoContext(context.Background(), "Without")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d records, want 2: %s", len(lines), out.String())
+	}
+	for idx, want := range []interface{}{"req-1", nil} {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[idx]), &record); err != nil {
+			t.Fatalf("failed to decode %s: %v", lines[idx], err)
+		}
+		if got := record[RequestIdKey]; got != want {
+			t.Errorf("record %d %s = %v, want %v", idx, RequestIdKey, got, want)
+		}
+	}
+}
@@ -1,14 +1,57 @@
 package main
 
 import (
-	"github.com/joho/godotenv"
+	"log/slog"
+	"os"
+
 	"github.com/katakeda/lantrn-api-go/app"
+	"github.com/katakeda/lantrn-api-go/config"
+	"github.com/katakeda/lantrn-api-go/logging"
+	"github.com/katakeda/lantrn-api-go/poller"
 )
 
 func main() {
-	godotenv.Load()
+	slog.SetDefault(logging.New(os.Stdout, slog.LevelInfo))
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		slog.Error("Failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	schedulerConfig := newSchedulerConfig(cfg)
+	if cfg.PollerEnabled {
+		if err := schedulerConfig.Validate(); err != nil {
+			slog.Error("Invalid poller config", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	level, _ := cfg.Level()
+	slog.SetDefault(logging.New(os.Stdout, level))
+	slog.Info("Starting", "config", cfg)
 
 	app := app.App{}
-	app.Initialize()
+	app.Initialize(cfg, schedulerConfig)
 	app.Run()
 }
+
+func newSchedulerConfig(cfg *config.Config) poller.SchedulerConfig {
+	return poller.SchedulerConfig{
+		TickInterval:           cfg.PollerTickInterval,
+		MinInterval:            cfg.PollerMinInterval,
+		MaxInterval:            cfg.PollerMaxInterval,
+		SubscriptionWeight:     cfg.PollerSubscriptionWeight,
+		SubscriptionSaturation: cfg.PollerSubscriptionSaturation,
+		ProximityWeight:        cfg.PollerProximityWeight,
+		ProximityHorizon:       cfg.PollerProximityHorizon,
+		ChurnWeight:            cfg.PollerChurnWeight,
+		ChurnSaturation:        cfg.PollerChurnSaturation,
+		ChurnDecay:             cfg.PollerChurnDecay,
+		MaxConcurrentPerHost:   cfg.PollerMaxConcurrentPerHost,
+		MaxWindow:              cfg.PollerMaxWindow,
+		BackoffBase:            cfg.PollerBackoffBase,
+		BackoffMax:             cfg.PollerBackoffMax,
+		BackoffJitter:          cfg.PollerBackoffJitter,
+	}
+}
@@ -0,0 +1,80 @@
+// Package metrics exposes Prometheus metrics for the HTTP API, the database
+// and the poller on the default registry, which the internal metrics listener
+// serves on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "lantrn"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "query_duration_seconds",
+		Help:      "Repository call latency by method and result.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method", "result"})
+
+	checks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "poller",
+		Name:      "checks_total",
+		Help:      "Facility availability checks by result.",
+	}, []string{"result"})
+
+	openings = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "poller",
+		Name:      "openings_total",
+		Help:      "Campsite dates found newly available.",
+	})
+
+	matches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "poller",
+		Name:      "matches_total",
+		Help:      "Subscription matches handed to the notifier by result.",
+	}, []string{"result"})
+)
+
+// ObserveCheck counts a facility check.
+func ObserveCheck(err error) {
+	checks.WithLabelValues(result(err)).Inc()
+}
+
+// AddOpenings counts campsite dates that became available.
+func AddOpenings(count int) {
+	openings.Add(float64(count))
+}
+
+// ObserveMatch counts a subscription match handed to the notifier. Whether a
+// subscriber is actually told depends on the notifier.
+func ObserveMatch(err error) {
+	matches.WithLabelValues(result(err)).Inc()
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolAcquiredConns = poolDesc("acquired_conns", "Connections currently in use.")
+	poolIdleConns     = poolDesc("idle_conns", "Connections currently idle.")
+	poolTotalConns    = poolDesc("total_conns", "Connections open or being opened.")
+	poolMaxConns      = poolDesc("max_conns", "Largest number of connections the pool opens.")
+	poolAcquires      = poolDesc("acquires_total", "Connections acquired from the pool.")
+	poolEmptyAcquires = poolDesc("empty_acquires_total", "Acquires that had to wait for a connection.")
+	poolAcquireWait   = poolDesc("acquire_duration_seconds_total", "Time spent acquiring connections.")
+)
+
+func poolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, []string{"pool"}, nil)
+}
+
+// poolCollector reads the stats of the pools whenever it is scraped.
+type poolCollector map[string]*pgxpool.Pool
+
+// RegisterPools exposes the stats of pools, labelled with their key to tell
+// the primary from the read replica.
+func RegisterPools(pools map[string]*pgxpool.Pool) error {
+	return prometheus.Register(poolCollector(pools))
+}
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{poolAcquiredConns, poolIdleConns, poolTotalConns, poolMaxConns, poolAcquires, poolEmptyAcquires, poolAcquireWait} {
+		ch <- desc
+	}
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, pool := range c {
+		collectPool(ch, name, pool.Stat())
+	}
+}
+
+func collectPool(ch chan<- prometheus.Metric, name string, stat *pgxpool.Stat) {
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()), name)
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(stat.IdleConns()), name)
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(stat.TotalConns()), name)
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(stat.MaxConns()), name)
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(stat.AcquireCount()), name)
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()), name)
+	ch <- prometheus.MustNewConstMetric(poolAcquireWait, prometheus.CounterValue, stat.AcquireDuration().Seconds(), name)
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+// repository times every query method of the repository it wraps.
+// Transaction control passes straight through, so time spent inside WithTx is
+// attributed to the calls made within it.
+type repository struct {
+	repo repositories.IRepository
+}
+
+var _ repositories.IRepository = repository{}
+
+// InstrumentRepository wraps repo so its query durations are recorded.
+func InstrumentRepository(repo repositories.IRepository) repositories.IRepository {
+	return repository{repo: repo}
+}
+
+func (r repository) BeginTxn(ctx context.Context) (context.Context, error) {
+	return r.repo.BeginTxn(ctx)
+}
+
+func (r repository) CommitTxn(ctx context.Context) error {
+	return r.repo.CommitTxn(ctx)
+}
+
+func (r repository) RollbackTxn(ctx context.Context) error {
+	return r.repo.RollbackTxn(ctx)
+}
+
+func (r repository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.repo.WithTx(ctx, fn)
+}
+
+func observeQuery(method string, start time.Time, err *error) {
+	queryDuration.WithLabelValues(method, result(*err)).Observe(time.Since(start).Seconds())
+}
+
+func (r repository) GetFacilities(ctx context.Context, filter repositories.GetFacilitiesFilter) (result *repositories.GetFacilitiesResponse, err error) {
+	defer observeQuery("GetFacilities", time.Now(), &err)
+	return r.repo.GetFacilities(ctx, filter)
+}
+
+func (r repository) GetFacility(ctx context.Context, id string) (result *repositories.Facility, err error) {
+	defer observeQuery("GetFacility", time.Now(), &err)
+	return r.repo.GetFacility(ctx, id)
+}
+
+func (r repository) GetFacilityDemands(ctx context.Context) (result []repositories.FacilityDemand, err error) {
+	defer observeQuery("GetFacilityDemands", time.Now(), &err)
+	return r.repo.GetFacilityDemands(ctx)
+}
+
+func (r repository) GetCampsites(ctx context.Context, filter repositories.GetCampsitesFilter) (result *repositories.GetCampsitesResponse, err error) {
+	defer observeQuery("GetCampsites", time.Now(), &err)
+	return r.repo.GetCampsites(ctx, filter)
+}
+
+func (r repository) GetFacilityCampsites(ctx context.Context, facilityId int) (result []repositories.Campsite, err error) {
+	defer observeQuery("GetFacilityCampsites", time.Now(), &err)
+	return r.repo.GetFacilityCampsites(ctx, facilityId)
+}
+
+func (r repository) UpsertCampsites(ctx context.Context, payloads []repositories.UpsertCampsitePayload) (result []repositories.Campsite, err error) {
+	defer observeQuery("UpsertCampsites", time.Now(), &err)
+	return r.repo.UpsertCampsites(ctx, payloads)
+}
+
+func (r repository) GetAvailability(ctx context.Context, filter repositories.GetAvailabilityFilter) (result *repositories.GetAvailabilityResponse, err error) {
+	defer observeQuery("GetAvailability", time.Now(), &err)
+	return r.repo.GetAvailability(ctx, filter)
+}
+
+func (r repository) GetAvailabilityHistory(ctx context.Context, filter repositories.GetAvailabilityFilter) (result *repositories.GetAvailabilityHistoryResponse, err error) {
+	defer observeQuery("GetAvailabilityHistory", time.Now(), &err)
+	return r.repo.GetAvailabilityHistory(ctx, filter)
+}
+
+func (r repository) GetLatestAvailabilitySnapshots(ctx context.Context, filter repositories.GetAvailabilityFilter) (result []repositories.AvailabilitySnapshot, err error) {
+	defer observeQuery("GetLatestAvailabilitySnapshots", time.Now(), &err)
+	return r.repo.GetLatestAvailabilitySnapshots(ctx, filter)
+}
+
+func (r repository) CreateAvailabilitySnapshots(ctx context.Context, payloads []repositories.CreateAvailabilitySnapshotPayload) (err error) {
+	defer observeQuery("CreateAvailabilitySnapshots", time.Now(), &err)
+	return r.repo.CreateAvailabilitySnapshots(ctx, payloads)
+}
+
+func (r repository) GetSubscriptions(ctx context.Context, filter repositories.GetSubscriptionsFilter) (result *repositories.GetSubscriptionsResponse, err error) {
+	defer observeQuery("GetSubscriptions", time.Now(), &err)
+	return r.repo.GetSubscriptions(ctx, filter)
+}
+
+func (r repository) GetSubscription(ctx context.Context, id string) (result *repositories.Subscription, err error) {
+	defer observeQuery("GetSubscription", time.Now(), &err)
+	return r.repo.GetSubscription(ctx, id)
+}
+
+func (r repository) GetActiveSubscriptions(ctx context.Context, filter repositories.GetActiveSubscriptionsFilter) (result []repositories.Subscription, err error) {
+	defer observeQuery("GetActiveSubscriptions", time.Now(), &err)
+	return r.repo.GetActiveSubscriptions(ctx, filter)
+}
+
+func (r repository) CreateSubscription(ctx context.Context, payload repositories.CreateSubscriptionPayload) (result *repositories.Subscription, err error) {
+	defer observeQuery("CreateSubscription", time.Now(), &err)
+	return r.repo.CreateSubscription(ctx, payload)
+}
+
+func (r repository) UpdateSubscription(ctx context.Context, id string, payload repositories.UpdateSubscriptionPayload) (result *repositories.Subscription, err error) {
+	defer observeQuery("UpdateSubscription", time.Now(), &err)
+	return r.repo.UpdateSubscription(ctx, id, payload)
+}
+
+func (r repository) GetSubscriptionTokens(ctx context.Context, filter repositories.GetSubscriptionTokensFilter) (result *repositories.GetSubscriptionTokensResponse, err error) {
+	defer observeQuery("GetSubscriptionTokens", time.Now(), &err)
+	return r.repo.GetSubscriptionTokens(ctx, filter)
+}
+
+func (r repository) GetPendingNotifications(ctx context.Context, filter repositories.GetPendingNotificationsFilter) (result []repositories.Notification, err error) {
+	defer observeQuery("GetPendingNotifications", time.Now(), &err)
+	return r.repo.GetPendingNotifications(ctx, filter)
+}
+
+func (r repository) CreateNotifications(ctx context.Context, payloads []repositories.CreateNotificationPayload) (err error) {
+	defer observeQuery("CreateNotifications", time.Now(), &err)
+	return r.repo.CreateNotifications(ctx, payloads)
+}
+
+func (r repository) MarkNotificationsDelivered(ctx context.Context, ids []int) (err error) {
+	defer observeQuery("MarkNotificationsDelivered", time.Now(), &err)
+	return r.repo.MarkNotificationsDelivered(ctx, ids)
+}
+
+func (r repository) CreateSubscriptionToken(ctx context.Context, payload repositories.CreateSubscriptionTokenPayload) (result *repositories.SubscriptionToken, err error) {
+	defer observeQuery("CreateSubscriptionToken", time.Now(), &err)
+	return r.repo.CreateSubscriptionToken(ctx, payload)
+}
@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/katakeda/lantrn-api-go/errs"
+	"github.com/katakeda/lantrn-api-go/logging"
+)
+
+type ErrorBody struct {
+	Code      errs.Code   `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details"`
+	RequestId string      `json:"requestId"`
+}
+
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// LegacyError is how version 1 answers a handler's failures: 500 with
+// Message, or 400 when the request body couldn't be bound, the way handlers
+// answered before errors were typed. Handlers attach it as the error's meta.
+type LegacyError struct {
+	Message string
+}
+
+// ErrorHandler renders the last error a handler attached with c.Error, unless
+// the handler already wrote a response. Version 1 keeps the bare message
+// string body it always had, version 2 gets the structured error.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) <= 0 || c.Writer.Written() {
+			return
+		}
+
+		last := c.Errors.Last()
+		err := errs.From(last.Err, "Something went wrong")
+		if RequestedAPIVersion(c) < APIVersion2 {
+			if legacy, ok := last.Meta.(LegacyError); ok {
+				status := http.StatusInternalServerError
+				if len(c.Errors.ByType(gin.ErrorTypeBind)) > 0 {
+					status = http.StatusBadRequest
+				}
+				c.JSON(status, legacy.Message)
+				return
+			}
+
+			c.JSON(err.Status(), err.Message)
+			return
+		}
+
+		c.JSON(err.Status(), ErrorResponse{
+			Error: ErrorBody{
+				Code:      err.Code,
+				Message:   err.Message,
+				Details:   err.Details,
+				RequestId: logging.RequestId(c.Request.Context()),
+			},
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/katakeda/lantrn-api-go/logging"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+// validRequestId keeps IDs passed in by clients or proxies from injecting
+// anything into logs.
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// quietPaths are polled by Fly, and only logged at debug level unless they
+// fail.
+var quietPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// RequestId reuses the request ID header when it is a sane value and makes
+// one up otherwise. The ID is echoed in the response and carried by the
+// request context, so everything logged while handling it can be tied back to
+// the request.
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIdHeader)
+		if !validRequestId.MatchString(id) {
+			id = newRequestId()
+		}
+
+		c.Header(RequestIdHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestId(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// Logger logs every request once it has been handled. The query string is
+// left out as it can hold emails and tokens.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case quietPaths[c.Request.URL.Path]:
+			level = slog.LevelDebug
+		}
+
+		slog.LogAttrs(c.Request.Context(), level, "Handled request",
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// Recovery answers 500 to requests whose handler panicked and logs the panic
+// with the request ID, in place of gin's plain text recovery log.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered interface{}) {
+		slog.ErrorContext(c.Request.Context(), "Recovered from panic",
+			"error", fmt.Sprint(recovered),
+			"stack", string(debug.Stack()),
+		)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
+
+func newRequestId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,98 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/katakeda/lantrn-api-go/logging"
+	"github.com/katakeda/lantrn-api-go/middlewares"
+)
+
+var generatedId = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+func TestRequestId(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "valid", header: "req-1.A_b", keep: true},
+		{name: "longest valid", header: strings.Repeat("z", 64), keep: true},
+		{name: "missing"},
+		{name: "oversized", header: strings.Repeat("z", 65)},
+		{name: "spaces", header: "req 1"},
+		{name: "log injection", header: `req" level=ERROR msg="forged`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := logging.New(&out, slog.LevelInfo)
+
+			var seen string
+			router := gin.New()
+			router.Use(middlewares.RequestId())
+			router.GET("/", func(c *gin.Context) {
+				seen = logging.RequestId(c.Request.Context())
+				logger.InfoContext(c.Request.Context(), "Handling")
+				c.Status(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(middlewares.RequestIdHeader, tt.header)
+			}
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, req)
+
+			id := res.Header().Get(middlewares.RequestIdHeader)
+			if tt.keep && id != tt.header {
+				t.Errorf("%s = %q, want the incoming %q", middlewares.RequestIdHeader, id, tt.header)
+			}
+			if !tt.keep && !generatedId.MatchString(id) {
+				t.Errorf("%s = %q, want a generated ID in place of %q", middlewares.RequestIdHeader, id, tt.header)
+			}
+
+			if seen != id {
+				t.Errorf("context request ID = %q, want the echoed %q", seen, id)
+			}
+
+			var record map[string]interface{}
+			if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+				t.Fatalf("failed to decode %s: %v", out.Bytes(), err)
+			}
+			if record[logging.RequestIdKey] != id {
+				t.Errorf("logged %s = %v, want %q", logging.RequestIdKey, record[logging.RequestIdKey], id)
+			}
+		})
+	}
+}
+
+func TestRequestIdIsUnique(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middlewares.RequestId())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		id := res.Header().Get(middlewares.RequestIdHeader)
+		if seen[id] {
+			t.Fatalf("request ID %q was generated twice", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	APIVersion1 = 1
+	APIVersion2 = 2
+
+	APIVersionKey    = "apiVersion"
+	APIVersionHeader = "X-API-Version"
+)
+
+// APIVersion pins every request of a route group to version.
+func APIVersion(version int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(APIVersionKey, version)
+		c.Next()
+	}
+}
+
+// RequestedAPIVersion is the version pinned by the route group, or the one
+// asked for with the version header on unversioned routes, defaulting to
+// version 1 so old clients keep getting the responses they expect.
+func RequestedAPIVersion(c *gin.Context) int {
+	if version := c.GetInt(APIVersionKey); version != 0 {
+		return version
+	}
+	if version, err := strconv.Atoi(c.GetHeader(APIVersionHeader)); err == nil && version >= APIVersion1 && version <= APIVersion2 {
+		return version
+	}
+	return APIVersion1
+}
+
+// Deprecated marks responses of unversioned routes as deprecated and points
+// clients at the same route under successorPrefix.
+func Deprecated(successorPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successorPrefix, c.Request.URL.Path))
+		c.Next()
+	}
+}
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE IF NOT EXISTS campsite_id_seq;
+CREATE TABLE "campsite" (
+    "id" int4 NOT NULL DEFAULT nextval('campsite_id_seq'::regclass),
+    "name" varchar NOT NULL DEFAULT ''::character varying,
+    "site_type" varchar(50),
+    "max_occupancy" int4,
+    "equipment_allowed" varchar[] NOT NULL DEFAULT '{}',
+    "loop" varchar,
+    "campsite_id" varchar NOT NULL,
+    "facility_id" int4 NOT NULL,
+    CONSTRAINT "campsite_facility_id_fkey" FOREIGN KEY ("facility_id") REFERENCES "public"."facility"("id"),
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "campsite_campsite_id_idx" ON "campsite" USING BTREE ("campsite_id");
+CREATE INDEX "campsite_facility_id_idx" ON "campsite" USING BTREE ("facility_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX "campsite_facility_id_idx";
+DROP INDEX "campsite_campsite_id_idx";
+DROP TABLE "campsite";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "subscription" ADD COLUMN campsite_ids int4[];
+ALTER TABLE "subscription" ADD COLUMN site_type VARCHAR(50);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "subscription" DROP COLUMN site_type;
+ALTER TABLE "subscription" DROP COLUMN campsite_ids;
+-- +goose StatementEnd
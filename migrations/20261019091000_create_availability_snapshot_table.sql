@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE IF NOT EXISTS availability_snapshot_id_seq;
+CREATE TABLE "availability_snapshot" (
+    "id" int8 NOT NULL DEFAULT nextval('availability_snapshot_id_seq'::regclass),
+    "facility_id" int4 NOT NULL,
+    "campsite_id" int4,
+    "date" date NOT NULL,
+    "status" varchar(50) NOT NULL,
+    "observed_at" timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT "availability_snapshot_facility_id_fkey" FOREIGN KEY ("facility_id") REFERENCES "public"."facility"("id"),
+    CONSTRAINT "availability_snapshot_campsite_id_fkey" FOREIGN KEY ("campsite_id") REFERENCES "public"."campsite"("id"),
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "availability_snapshot_facility_id_date_idx" ON "availability_snapshot" USING BTREE ("facility_id", "date");
+CREATE INDEX "availability_snapshot_campsite_id_date_observed_at_idx" ON "availability_snapshot" USING BTREE ("campsite_id", "date", "observed_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX "availability_snapshot_campsite_id_date_observed_at_idx";
+DROP INDEX "availability_snapshot_facility_id_date_idx";
+DROP TABLE "availability_snapshot";
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "subscription_token" ADD COLUMN purpose VARCHAR(50) NOT NULL DEFAULT 'manage';
+-- Tokens issued before this migration get the epoch rather than the time it
+-- ran, which would pass for their creation time. Only new tokens default to
+-- now().
+ALTER TABLE "subscription_token" ADD COLUMN created_at timestamptz NOT NULL DEFAULT 'epoch';
+ALTER TABLE "subscription_token" ALTER COLUMN created_at SET DEFAULT now();
+CREATE INDEX "subscription_token_subscription_id_idx" ON "subscription_token" USING BTREE ("subscription_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX "subscription_token_subscription_id_idx";
+ALTER TABLE "subscription_token" DROP COLUMN created_at;
+ALTER TABLE "subscription_token" DROP COLUMN purpose;
+-- +goose StatementEnd
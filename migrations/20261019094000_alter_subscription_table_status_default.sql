@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "subscription" ALTER COLUMN status SET DEFAULT 'active';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "subscription" ALTER COLUMN status DROP DEFAULT;
+-- +goose StatementEnd
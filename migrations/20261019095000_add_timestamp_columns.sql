@@ -0,0 +1,70 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Rows that predate these columns get the epoch rather than the time of the
+-- migration, so they can't be mistaken for rows created since. Only new rows
+-- default to now(). Snapshots already record when they were observed and
+-- tokens when they were created, so theirs are backfilled from that instead.
+ALTER TABLE "facility" ADD COLUMN created_at timestamptz NOT NULL DEFAULT 'epoch';
+ALTER TABLE "facility" ADD COLUMN updated_at timestamptz NOT NULL DEFAULT 'epoch';
+ALTER TABLE "facility_media" ADD COLUMN created_at timestamptz NOT NULL DEFAULT 'epoch';
+ALTER TABLE "facility_media" ADD COLUMN updated_at timestamptz NOT NULL DEFAULT 'epoch';
+ALTER TABLE "subscription" ADD COLUMN created_at timestamptz NOT NULL DEFAULT 'epoch';
+ALTER TABLE "subscription" ADD COLUMN updated_at timestamptz NOT NULL DEFAULT 'epoch';
+ALTER TABLE "subscription_token" ADD COLUMN updated_at timestamptz NOT NULL DEFAULT 'epoch';
+ALTER TABLE "campsite" ADD COLUMN created_at timestamptz NOT NULL DEFAULT 'epoch';
+ALTER TABLE "campsite" ADD COLUMN updated_at timestamptz NOT NULL DEFAULT 'epoch';
+ALTER TABLE "availability_snapshot" ADD COLUMN created_at timestamptz NOT NULL DEFAULT 'epoch';
+ALTER TABLE "availability_snapshot" ADD COLUMN updated_at timestamptz NOT NULL DEFAULT 'epoch';
+
+UPDATE "subscription_token" SET updated_at = created_at;
+UPDATE "availability_snapshot" SET created_at = observed_at, updated_at = observed_at;
+
+ALTER TABLE "facility" ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN updated_at SET DEFAULT now();
+ALTER TABLE "facility_media" ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN updated_at SET DEFAULT now();
+ALTER TABLE "subscription" ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN updated_at SET DEFAULT now();
+ALTER TABLE "subscription_token" ALTER COLUMN updated_at SET DEFAULT now();
+ALTER TABLE "campsite" ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN updated_at SET DEFAULT now();
+ALTER TABLE "availability_snapshot" ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN updated_at SET DEFAULT now();
+
+CREATE TRIGGER "facility_set_updated_at" BEFORE UPDATE ON "facility" FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER "facility_media_set_updated_at" BEFORE UPDATE ON "facility_media" FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER "subscription_set_updated_at" BEFORE UPDATE ON "subscription" FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER "subscription_token_set_updated_at" BEFORE UPDATE ON "subscription_token" FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER "campsite_set_updated_at" BEFORE UPDATE ON "campsite" FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER "availability_snapshot_set_updated_at" BEFORE UPDATE ON "availability_snapshot" FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE INDEX "subscription_created_at_idx" ON "subscription" USING BTREE ("created_at", "id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX "subscription_created_at_idx";
+
+DROP TRIGGER "availability_snapshot_set_updated_at" ON "availability_snapshot";
+DROP TRIGGER "campsite_set_updated_at" ON "campsite";
+DROP TRIGGER "subscription_token_set_updated_at" ON "subscription_token";
+DROP TRIGGER "subscription_set_updated_at" ON "subscription";
+DROP TRIGGER "facility_media_set_updated_at" ON "facility_media";
+DROP TRIGGER "facility_set_updated_at" ON "facility";
+
+ALTER TABLE "availability_snapshot" DROP COLUMN updated_at;
+ALTER TABLE "availability_snapshot" DROP COLUMN created_at;
+ALTER TABLE "campsite" DROP COLUMN updated_at;
+ALTER TABLE "campsite" DROP COLUMN created_at;
+ALTER TABLE "subscription_token" DROP COLUMN updated_at;
+ALTER TABLE "subscription" DROP COLUMN updated_at;
+ALTER TABLE "subscription" DROP COLUMN created_at;
+ALTER TABLE "facility_media" DROP COLUMN updated_at;
+ALTER TABLE "facility_media" DROP COLUMN created_at;
+ALTER TABLE "facility" DROP COLUMN updated_at;
+ALTER TABLE "facility" DROP COLUMN created_at;
+
+DROP FUNCTION set_updated_at();
+-- +goose StatementEnd
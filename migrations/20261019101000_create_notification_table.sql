@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE IF NOT EXISTS notification_id_seq;
+CREATE TABLE "notification" (
+    "id" int8 NOT NULL DEFAULT nextval('notification_id_seq'::regclass),
+    "subscription_id" int4 NOT NULL,
+    "facility_id" int4 NOT NULL,
+    "campsite_id" int4,
+    "date" date NOT NULL,
+    "previous_status" varchar(50) NOT NULL,
+    "status" varchar(50) NOT NULL,
+    "observed_at" timestamptz NOT NULL,
+    "delivered_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    "updated_at" timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT "notification_subscription_id_fkey" FOREIGN KEY ("subscription_id") REFERENCES "public"."subscription"("id"),
+    CONSTRAINT "notification_facility_id_fkey" FOREIGN KEY ("facility_id") REFERENCES "public"."facility"("id"),
+    CONSTRAINT "notification_campsite_id_fkey" FOREIGN KEY ("campsite_id") REFERENCES "public"."campsite"("id"),
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "notification_pending_facility_id_idx" ON "notification" USING BTREE ("facility_id", "id") WHERE "delivered_at" IS NULL;
+CREATE TRIGGER "notification_set_updated_at" BEFORE UPDATE ON "notification" FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER "notification_set_updated_at" ON "notification";
+DROP INDEX "notification_pending_facility_id_idx";
+DROP TABLE "notification";
+-- +goose StatementEnd
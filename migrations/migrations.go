@@ -0,0 +1,46 @@
+// Package migrations embeds the goose migration files so they can be applied
+// and checked from Go.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Version parses the version goose takes from a migration file name, such as
+// 20221208133730 from 20221208133730_create_facility_table.sql.
+func Version(name string) (int64, error) {
+	version, err := strconv.ParseInt(strings.SplitN(name, "_", 2)[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid migration name %s | %w", name, err)
+	}
+	return version, nil
+}
+
+// Latest returns the version of the newest embedded migration, which the
+// database must be at for this build to run against it.
+func Latest() (int64, error) {
+	names, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list migrations | %w", err)
+	}
+
+	var latest int64
+	for _, name := range names {
+		version, err := Version(name)
+		if err != nil {
+			return 0, err
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+
+	return latest, nil
+}
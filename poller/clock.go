@@ -0,0 +1,20 @@
+package poller
+
+import "time"
+
+// Clock is the scheduler's only source of time so it can be driven by a fake
+// clock in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
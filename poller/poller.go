@@ -0,0 +1,356 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/availability"
+	"github.com/katakeda/lantrn-api-go/metrics"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+const dateLayout = "2006-01-02"
+
+// Observation is a single campsite and date as reported upstream. CampsiteId
+// is the upstream campsite ID, not ours, and the campsite details are used to
+// create campsites we haven't seen yet.
+type Observation struct {
+	CampsiteId   string
+	Site         string
+	Loop         string
+	SiteType     string
+	MaxOccupancy int
+	Date         string
+	Status       string
+}
+
+type Fetcher interface {
+	FetchAvailability(ctx context.Context, facilityId string, from, to time.Time) ([]Observation, error)
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, match availability.Match) error
+}
+
+type Poller struct {
+	repo     repositories.IRepository
+	fetcher  Fetcher
+	notifier Notifier
+	now      func() time.Time
+}
+
+func NewPoller(repo repositories.IRepository, fetcher Fetcher, notifier Notifier) (*Poller, error) {
+	if repo == nil {
+		return nil, fmt.Errorf("repository is required to start a new poller")
+	}
+	if fetcher == nil {
+		return nil, fmt.Errorf("fetcher is required to start a new poller")
+	}
+	if notifier == nil {
+		notifier = LogNotifier{}
+	}
+
+	return &Poller{
+		repo:     repo,
+		fetcher:  fetcher,
+		notifier: notifier,
+		now:      time.Now,
+	}, nil
+}
+
+// Check fetches the current availability of a facility and stores whatever
+// changed since the last check, along with a notification for every
+// subscription a new opening matches. The notifications are sent once that is
+// committed, together with any an earlier check failed to send. Each
+// subscription's are marked delivered on their own, so only the ones that
+// failed are sent again.
+func (p *Poller) Check(ctx context.Context, facility repositories.Facility, from, to time.Time) (events []availability.ChangeEvent, err error) {
+	observations, err := p.fetcher.FetchAvailability(ctx, facility.FacilityId, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch availability | %w", err)
+	}
+
+	err = p.repo.WithTx(ctx, func(ctx context.Context) (err error) {
+		events, err = p.record(ctx, facility, observations, from, to)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	openings := 0
+	for _, event := range events {
+		if event.Type == availability.EventNewlyAvailable {
+			openings++
+		}
+	}
+	metrics.AddOpenings(openings)
+
+	if err := p.deliver(ctx, facility); err != nil {
+		return events, fmt.Errorf("failed to notify subscriptions | %w", err)
+	}
+
+	return events, nil
+}
+
+func (p *Poller) record(ctx context.Context, facility repositories.Facility, observations []Observation, from, to time.Time) ([]availability.ChangeEvent, error) {
+	campsites, err := p.syncCampsites(ctx, facility, observations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sync campsites | %w", err)
+	}
+
+	campsitesByUpstreamId := make(map[string]repositories.Campsite, len(campsites))
+	campsitesById := make(map[int]repositories.Campsite, len(campsites))
+	for _, campsite := range campsites {
+		campsitesByUpstreamId[campsite.CampsiteId] = campsite
+		campsitesById[campsite.Id] = campsite
+	}
+
+	observedAt := p.now()
+	current := make([]repositories.AvailabilitySnapshot, 0, len(observations))
+	for _, observation := range observations {
+		snapshot := repositories.AvailabilitySnapshot{
+			FacilityId: facility.Id,
+			Date:       observation.Date,
+			Status:     observation.Status,
+			ObservedAt: observedAt,
+		}
+		if observation.CampsiteId != "" {
+			campsite, ok := campsitesByUpstreamId[observation.CampsiteId]
+			if !ok {
+				continue
+			}
+			snapshot.CampsiteId = &campsite.Id
+		}
+		current = append(current, snapshot)
+	}
+
+	previous, err := p.repo.GetLatestAvailabilitySnapshots(ctx, repositories.GetAvailabilityFilter{
+		FacilityId: strconv.Itoa(facility.Id),
+		From:       from.Format(dateLayout),
+		To:         to.Format(dateLayout),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get previous availability | %w", err)
+	}
+
+	events := availability.Diff(previous, current)
+	if len(events) <= 0 {
+		return events, nil
+	}
+
+	payloads := make([]repositories.CreateAvailabilitySnapshotPayload, len(events))
+	for idx, event := range events {
+		payloads[idx] = repositories.CreateAvailabilitySnapshotPayload{
+			FacilityId: event.FacilityId,
+			CampsiteId: event.CampsiteId,
+			Date:       event.Date,
+			Status:     event.Status,
+			ObservedAt: event.ObservedAt,
+		}
+	}
+	if err := p.repo.CreateAvailabilitySnapshots(ctx, payloads); err != nil {
+		return nil, fmt.Errorf("failed to store availability | %w", err)
+	}
+
+	if err := p.queue(ctx, events, campsitesById); err != nil {
+		return nil, fmt.Errorf("failed to queue notifications | %w", err)
+	}
+
+	return events, nil
+}
+
+// syncCampsites returns the campsites of a facility, creating the ones only
+// known upstream so far.
+func (p *Poller) syncCampsites(ctx context.Context, facility repositories.Facility, observations []Observation) ([]repositories.Campsite, error) {
+	campsites, err := p.repo.GetFacilityCampsites(ctx, facility.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campsites | %w", err)
+	}
+
+	known := make(map[string]bool, len(campsites))
+	for _, campsite := range campsites {
+		known[campsite.CampsiteId] = true
+	}
+
+	payloads := []repositories.UpsertCampsitePayload{}
+	for _, observation := range observations {
+		if observation.CampsiteId == "" || known[observation.CampsiteId] {
+			continue
+		}
+		known[observation.CampsiteId] = true
+
+		payload := repositories.UpsertCampsitePayload{
+			Name:       observation.Site,
+			CampsiteId: observation.CampsiteId,
+			FacilityId: facility.Id,
+		}
+		if observation.SiteType != "" {
+			siteType := observation.SiteType
+			payload.SiteType = &siteType
+		}
+		if observation.Loop != "" {
+			loop := observation.Loop
+			payload.Loop = &loop
+		}
+		if observation.MaxOccupancy > 0 {
+			maxOccupancy := observation.MaxOccupancy
+			payload.MaxOccupancy = &maxOccupancy
+		}
+		payloads = append(payloads, payload)
+	}
+
+	created, err := p.repo.UpsertCampsites(ctx, payloads)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create campsites | %w", err)
+	}
+
+	return append(campsites, created...), nil
+}
+
+// queue stores a notification for every subscription a new opening matches,
+// to be sent by deliver.
+func (p *Poller) queue(ctx context.Context, events []availability.ChangeEvent, campsites map[int]repositories.Campsite) error {
+	dates := []string{}
+	seen := make(map[string]bool)
+	for _, event := range events {
+		if event.Type != availability.EventNewlyAvailable || seen[event.Date] {
+			continue
+		}
+		seen[event.Date] = true
+		dates = append(dates, event.Date)
+	}
+	if len(dates) <= 0 {
+		return nil
+	}
+
+	subscriptions, err := p.repo.GetActiveSubscriptions(ctx, repositories.GetActiveSubscriptionsFilter{
+		FacilityId:  events[0].FacilityId,
+		TargetDates: dates,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions | %w", err)
+	}
+
+	payloads := []repositories.CreateNotificationPayload{}
+	for _, match := range availability.MatchSubscriptions(events, subscriptions, campsites) {
+		for _, event := range match.Events {
+			payloads = append(payloads, repositories.CreateNotificationPayload{
+				SubscriptionId: match.Subscription.Id,
+				FacilityId:     event.FacilityId,
+				CampsiteId:     event.CampsiteId,
+				Date:           event.Date,
+				PreviousStatus: event.PreviousStatus,
+				Status:         event.Status,
+				ObservedAt:     event.ObservedAt,
+			})
+		}
+	}
+
+	return p.repo.CreateNotifications(ctx, payloads)
+}
+
+// deliver sends the pending notifications of a facility, one match per
+// subscription. Notifications of subscriptions that have been paused or
+// cancelled since are dropped by marking them delivered, so resuming a
+// subscription doesn't replay stale openings.
+func (p *Poller) deliver(ctx context.Context, facility repositories.Facility) error {
+	pending, err := p.repo.GetPendingNotifications(ctx, repositories.GetPendingNotificationsFilter{FacilityId: facility.Id})
+	if err != nil {
+		return fmt.Errorf("failed to get pending notifications | %w", err)
+	}
+	if len(pending) <= 0 {
+		return nil
+	}
+
+	dates := []string{}
+	order := []int{}
+	bySubscription := make(map[int][]repositories.Notification)
+	seen := make(map[string]bool)
+	for _, notification := range pending {
+		if !seen[notification.Date] {
+			seen[notification.Date] = true
+			dates = append(dates, notification.Date)
+		}
+		if _, ok := bySubscription[notification.SubscriptionId]; !ok {
+			order = append(order, notification.SubscriptionId)
+		}
+		bySubscription[notification.SubscriptionId] = append(bySubscription[notification.SubscriptionId], notification)
+	}
+
+	subscriptions, err := p.repo.GetActiveSubscriptions(ctx, repositories.GetActiveSubscriptionsFilter{
+		FacilityId:  facility.Id,
+		TargetDates: dates,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions | %w", err)
+	}
+	active := make(map[int]repositories.Subscription, len(subscriptions))
+	for _, subscription := range subscriptions {
+		active[subscription.Id] = subscription
+	}
+
+	var failed int
+	for _, subscriptionId := range order {
+		notifications := bySubscription[subscriptionId]
+		ids := make([]int, len(notifications))
+		for idx, notification := range notifications {
+			ids[idx] = notification.Id
+		}
+
+		if subscription, ok := active[subscriptionId]; ok {
+			err := p.notifier.Notify(ctx, newMatch(subscription, notifications))
+			metrics.ObserveMatch(err)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to notify subscription", "subscription_id", subscriptionId, "error", err)
+				failed++
+				continue
+			}
+		}
+
+		if err := p.repo.MarkNotificationsDelivered(ctx, ids); err != nil {
+			return fmt.Errorf("failed to mark notifications delivered | %w", err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d notifications failed", failed)
+	}
+
+	return nil
+}
+
+func newMatch(subscription repositories.Subscription, notifications []repositories.Notification) availability.Match {
+	match := availability.Match{Subscription: subscription}
+	for _, notification := range notifications {
+		match.Events = append(match.Events, availability.ChangeEvent{
+			Type:           availability.EventNewlyAvailable,
+			FacilityId:     notification.FacilityId,
+			CampsiteId:     notification.CampsiteId,
+			Date:           notification.Date,
+			PreviousStatus: notification.PreviousStatus,
+			Status:         notification.Status,
+			ObservedAt:     notification.ObservedAt,
+		})
+	}
+	return match
+}
+
+// Host reports the upstream host of the fetcher so the scheduler can cap
+// concurrent checks against it.
+func (p *Poller) Host(facility repositories.Facility) string {
+	if hoster, ok := p.fetcher.(interface{ Host() string }); ok {
+		return hoster.Host()
+	}
+	return "default"
+}
+
+// LogNotifier only logs matches and is used until a real notifier is set up.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, match availability.Match) error {
+	slog.InfoContext(ctx, "Subscription matched", "subscription_id", match.Subscription.Id, "openings", len(match.Events), "target_date", match.Subscription.TargetDate)
+	return nil
+}
@@ -0,0 +1,198 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/availability"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+type staticFetcher struct {
+	observations []Observation
+}
+
+func (f *staticFetcher) FetchAvailability(ctx context.Context, facilityId string, from, to time.Time) ([]Observation, error) {
+	return f.observations, nil
+}
+
+// recordingNotifier records the matches it was given and fails those of the
+// emails in failing.
+type recordingNotifier struct {
+	failing map[string]bool
+	matches []availability.Match
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, match availability.Match) error {
+	if n.failing[match.Subscription.Email] {
+		return errors.New("smtp down")
+	}
+	n.matches = append(n.matches, match)
+	return nil
+}
+
+// count returns how many matches went out for an email.
+func (n *recordingNotifier) count(email string) int {
+	count := 0
+	for _, match := range n.matches {
+		if match.Subscription.Email == email {
+			count++
+		}
+	}
+	return count
+}
+
+func TestPollerRetriesOnlyFailedNotifications(t *testing.T) {
+	ctx := context.Background()
+	repo, facility := newPollerRepository(t)
+	from := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	for _, email := range []string{"working@example.com", "bouncing@example.com"} {
+		if _, err := repo.CreateSubscription(ctx, repositories.CreateSubscriptionPayload{
+			Email:      email,
+			TargetDate: "2030-06-02",
+			FacilityId: facility.Id,
+		}); err != nil {
+			t.Fatalf("CreateSubscription: %v", err)
+		}
+	}
+
+	fetcher := &staticFetcher{}
+	notifier := &recordingNotifier{failing: map[string]bool{"bouncing@example.com": true}}
+	p, err := NewPoller(repo, fetcher, notifier)
+	if err != nil {
+		t.Fatalf("NewPoller: %v", err)
+	}
+
+	observe := func(status string) {
+		fetcher.observations = []Observation{{CampsiteId: "site-1", Site: "001", Date: "2030-06-02", Status: status}}
+	}
+
+	// The site is already open the first time it is seen, which is no news.
+	observe(repositories.AvailabilityStatusAvailable)
+	events, err := p.Check(ctx, *facility, from, to)
+	if err != nil {
+		t.Fatalf("first Check: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != availability.EventFirstSeen {
+		t.Fatalf("first Check events = %+v, want one first_seen", events)
+	}
+	if len(notifier.matches) != 0 {
+		t.Fatalf("first Check notified %+v, want nothing", notifier.matches)
+	}
+
+	observe(repositories.AvailabilityStatusReserved)
+	if _, err := p.Check(ctx, *facility, from, to); err != nil {
+		t.Fatalf("booking Check: %v", err)
+	}
+
+	// It opens again. One subscriber is told and the other fails, which is
+	// reported, but the change is stored either way.
+	observe(repositories.AvailabilityStatusAvailable)
+	events, err = p.Check(ctx, *facility, from, to)
+	if err == nil {
+		t.Fatal("Check with a failing notification succeeded, want an error")
+	}
+	if len(events) != 1 || events[0].Type != availability.EventNewlyAvailable {
+		t.Fatalf("opening Check events = %+v, want one newly_available", events)
+	}
+	if working, bouncing := notifier.count("working@example.com"), notifier.count("bouncing@example.com"); working != 1 || bouncing != 0 {
+		t.Fatalf("opening Check notified working %d and bouncing %d times, want 1 and 0", working, bouncing)
+	}
+
+	// Polling again while the address still fails finds no new change and
+	// doesn't tell the working subscriber twice.
+	if _, err := p.Check(ctx, *facility, from, to); err == nil {
+		t.Fatal("repeat Check with a failing notification succeeded, want an error")
+	}
+	if working := notifier.count("working@example.com"); working != 1 {
+		t.Fatalf("repeat Check notified working %d times, want 1", working)
+	}
+
+	// Once the address recovers only its notification is sent.
+	notifier.failing = nil
+	events, err = p.Check(ctx, *facility, from, to)
+	if err != nil {
+		t.Fatalf("recovered Check: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("recovered Check events = %+v, want nothing new", events)
+	}
+	if working, bouncing := notifier.count("working@example.com"), notifier.count("bouncing@example.com"); working != 1 || bouncing != 1 {
+		t.Fatalf("recovered Check notified working %d and bouncing %d times, want 1 each", working, bouncing)
+	}
+	if match := notifier.matches[len(notifier.matches)-1]; len(match.Events) != 1 || match.Events[0].Date != "2030-06-02" || match.Events[0].Type != availability.EventNewlyAvailable {
+		t.Errorf("retried match events = %+v, want the opening on 2030-06-02", match.Events)
+	}
+
+	if _, err := p.Check(ctx, *facility, from, to); err != nil {
+		t.Fatalf("final Check: %v", err)
+	}
+	if len(notifier.matches) != 2 {
+		t.Fatalf("final Check notified %d matches in total, want 2", len(notifier.matches))
+	}
+}
+
+func TestPollerDropsNotificationsOfPausedSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	repo, facility := newPollerRepository(t)
+	from := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	subscription, err := repo.CreateSubscription(ctx, repositories.CreateSubscriptionPayload{
+		Email:      "camper@example.com",
+		TargetDate: "2030-06-02",
+		FacilityId: facility.Id,
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	fetcher := &staticFetcher{}
+	notifier := &recordingNotifier{failing: map[string]bool{"camper@example.com": true}}
+	p, err := NewPoller(repo, fetcher, notifier)
+	if err != nil {
+		t.Fatalf("NewPoller: %v", err)
+	}
+
+	for _, status := range []string{repositories.AvailabilityStatusReserved, repositories.AvailabilityStatusAvailable} {
+		fetcher.observations = []Observation{{CampsiteId: "site-1", Site: "001", Date: "2030-06-02", Status: status}}
+		p.Check(ctx, *facility, from, to)
+	}
+
+	paused := repositories.SubscriptionStatusPaused
+	if _, err := repo.UpdateSubscription(ctx, strconv.Itoa(subscription.Id), repositories.UpdateSubscriptionPayload{Status: &paused}); err != nil {
+		t.Fatalf("UpdateSubscription: %v", err)
+	}
+	notifier.failing = nil
+	if _, err := p.Check(ctx, *facility, from, to); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	pending, err := repo.GetPendingNotifications(ctx, repositories.GetPendingNotificationsFilter{FacilityId: facility.Id})
+	if err != nil {
+		t.Fatalf("GetPendingNotifications: %v", err)
+	}
+	if len(notifier.matches) != 0 || len(pending) != 0 {
+		t.Errorf("notified %d matches with %d pending, want the paused subscription's dropped", len(notifier.matches), len(pending))
+	}
+}
+
+func newPollerRepository(t *testing.T) (*repositories.MemoryRepository, *repositories.Facility) {
+	t.Helper()
+
+	repo, err := repositories.NewMemoryRepository()
+	if err != nil {
+		t.Fatalf("NewMemoryRepository: %v", err)
+	}
+	facility, err := repo.AddFacility(context.Background(), repositories.Facility{Name: "Upper Pines", FacilityId: "232447"})
+	if err != nil {
+		t.Fatalf("AddFacility: %v", err)
+	}
+
+	return repo, facility
+}
@@ -0,0 +1,49 @@
+package poller
+
+import (
+	"context"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/recgov"
+)
+
+// RecGovFetcher fetches availability from recreation.gov, or anything
+// serving the same API such as recgovtest.
+type RecGovFetcher struct {
+	client *recgov.Client
+}
+
+func NewRecGovFetcher(client *recgov.Client) *RecGovFetcher {
+	return &RecGovFetcher{
+		client: client,
+	}
+}
+
+func (f *RecGovFetcher) Host() string {
+	return f.client.Host()
+}
+
+func (f *RecGovFetcher) FetchAvailability(ctx context.Context, facilityId string, from, to time.Time) ([]Observation, error) {
+	availabilities, err := f.client.GetAvailability(ctx, facilityId, from, to)
+	if err != nil {
+		if recgov.Temporary(err) {
+			return nil, &UpstreamError{Err: err}
+		}
+		return nil, err
+	}
+
+	observations := make([]Observation, len(availabilities))
+	for idx, availability := range availabilities {
+		observations[idx] = Observation{
+			CampsiteId:   availability.Campsite.CampsiteId,
+			Site:         availability.Campsite.Site,
+			Loop:         availability.Campsite.Loop,
+			SiteType:     availability.Campsite.CampsiteType,
+			MaxOccupancy: availability.Campsite.MaxNumPeople,
+			Date:         availability.Date,
+			Status:       availability.Status,
+		}
+	}
+
+	return observations, nil
+}
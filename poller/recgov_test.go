@@ -0,0 +1,128 @@
+package poller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/availability"
+	"github.com/katakeda/lantrn-api-go/recgov"
+	"github.com/katakeda/lantrn-api-go/recgov/recgovtest"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+func TestPollerAgainstRecGov(t *testing.T) {
+	ctx := context.Background()
+	server := recgovtest.NewServer()
+	defer server.Close()
+
+	repo, facility := newPollerRepository(t)
+	if _, err := repo.CreateSubscription(ctx, repositories.CreateSubscriptionPayload{
+		Email:      "camper@example.com",
+		TargetDate: "2023-07-05",
+		FacilityId: facility.Id,
+	}); err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	client := recgov.NewClient(recgov.WithBaseURL(server.URL), recgov.WithRateLimit(0), recgov.WithRetries(1, time.Millisecond))
+	notifier := &recordingNotifier{}
+	p, err := NewPoller(repo, NewRecGovFetcher(client), notifier)
+	if err != nil {
+		t.Fatalf("NewPoller: %v", err)
+	}
+	if host := p.Host(*facility); host != server.Listener.Addr().String() {
+		t.Errorf("Host() = %s, want %s", host, server.Listener.Addr().String())
+	}
+
+	from := time.Date(2023, 7, 4, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 7, 6, 0, 0, 0, 0, time.UTC)
+
+	// The first check stores the fixture as the baseline and creates its
+	// four campsites.
+	events, err := p.Check(ctx, *facility, from, to)
+	if err != nil {
+		t.Fatalf("first Check: %v", err)
+	}
+	if len(events) != 12 {
+		t.Fatalf("first Check got %d events, want 12", len(events))
+	}
+	campsites, err := repo.GetFacilityCampsites(ctx, facility.Id)
+	if err != nil {
+		t.Fatalf("GetFacilityCampsites: %v", err)
+	}
+	if len(campsites) != 4 {
+		t.Fatalf("first Check created %d campsites, want 4", len(campsites))
+	}
+	if len(notifier.matches) != 0 {
+		t.Fatalf("first Check notified %+v, want nothing", notifier.matches)
+	}
+
+	// Site 001 is cancelled on the subscription's date.
+	month := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	server.SetMonth("232447", month, cancelFixture(t, "232447_2023-07.json", "70", "2023-07-05T00:00:00Z"))
+
+	events, err = p.Check(ctx, *facility, from, to)
+	if err != nil {
+		t.Fatalf("second Check: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != availability.EventNewlyAvailable || events[0].Date != "2023-07-05" {
+		t.Fatalf("second Check events = %+v, want site 001 newly available on 2023-07-05", events)
+	}
+	if len(notifier.matches) != 1 || notifier.matches[0].Subscription.Email != "camper@example.com" {
+		t.Fatalf("second Check notified %+v, want the subscription once", notifier.matches)
+	}
+}
+
+func TestRecGovFetcherReportsUpstreamErrors(t *testing.T) {
+	server := recgovtest.NewServer()
+	defer server.Close()
+
+	client := recgov.NewClient(recgov.WithBaseURL(server.URL), recgov.WithRateLimit(0), recgov.WithRetries(1, time.Millisecond))
+	fetcher := NewRecGovFetcher(client)
+	from := time.Date(2023, 7, 4, 0, 0, 0, 0, time.UTC)
+
+	// Upstream limiting us is the host's problem, so the scheduler backs off
+	// every facility on it.
+	server.FailNext(http.StatusTooManyRequests, http.StatusTooManyRequests)
+	_, err := fetcher.FetchAvailability(context.Background(), "232447", from, from)
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		t.Errorf("FetchAvailability error = %v, want an UpstreamError", err)
+	}
+
+	// A bad request only concerns the facility.
+	server.FailNext(http.StatusBadRequest)
+	_, err = fetcher.FetchAvailability(context.Background(), "232447", from, from)
+	if err == nil || errors.As(err, &upstreamErr) {
+		t.Errorf("FetchAvailability error = %v, want a plain error", err)
+	}
+}
+
+// cancelFixture returns a recorded month with one campsite made available on
+// one day.
+func cancelFixture(t *testing.T, name, campsiteId, timestamp string) []byte {
+	t.Helper()
+
+	body, err := os.ReadFile("../recgov/recgovtest/fixtures/" + name)
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %v", name, err)
+	}
+
+	var response recgov.MonthResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatalf("failed to parse fixture %s: %v", name, err)
+	}
+	response.Campsites[campsiteId].Availabilities[timestamp] = repositories.AvailabilityStatusAvailable
+
+	body, err = json.Marshal(response)
+	if err != nil {
+		t.Fatalf("failed to encode fixture %s: %v", name, err)
+	}
+
+	return body
+}
@@ -0,0 +1,451 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/availability"
+	"github.com/katakeda/lantrn-api-go/metrics"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+// Checker checks a single facility. Host groups facilities that share an
+// upstream so concurrency can be capped per upstream host.
+type Checker interface {
+	Check(ctx context.Context, facility repositories.Facility, from, to time.Time) ([]availability.ChangeEvent, error)
+	Host(facility repositories.Facility) string
+}
+
+type SchedulerConfig struct {
+	// TickInterval is how often the scheduler looks for facilities due for a
+	// check. Facilities themselves are checked every MinInterval to
+	// MaxInterval depending on their priority.
+	TickInterval time.Duration
+	MinInterval  time.Duration
+	MaxInterval  time.Duration
+
+	// Priority is a weighted average of subscription demand, how close the
+	// nearest target date is and how often the facility changed recently,
+	// each scaled to [0, 1] by its saturation point.
+	SubscriptionWeight     float64
+	SubscriptionSaturation int
+	ProximityWeight        float64
+	ProximityHorizon       time.Duration
+	ChurnWeight            float64
+	ChurnSaturation        float64
+	ChurnDecay             float64
+
+	MaxConcurrentPerHost int
+	MaxWindow            time.Duration
+
+	BackoffBase   time.Duration
+	BackoffMax    time.Duration
+	BackoffJitter float64
+}
+
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		TickInterval:           15 * time.Second,
+		MinInterval:            2 * time.Minute,
+		MaxInterval:            30 * time.Minute,
+		SubscriptionWeight:     0.5,
+		SubscriptionSaturation: 20,
+		ProximityWeight:        0.3,
+		ProximityHorizon:       14 * 24 * time.Hour,
+		ChurnWeight:            0.2,
+		ChurnSaturation:        10,
+		ChurnDecay:             0.5,
+		MaxConcurrentPerHost:   2,
+		MaxWindow:              180 * 24 * time.Hour,
+		BackoffBase:            time.Minute,
+		BackoffMax:             time.Hour,
+		BackoffJitter:          0.2,
+	}
+}
+
+func (c SchedulerConfig) Validate() error {
+	switch {
+	case c.TickInterval <= 0:
+		return fmt.Errorf("tick interval must be positive")
+	case c.MinInterval <= 0 || c.MaxInterval < c.MinInterval:
+		return fmt.Errorf("intervals must be positive with min interval not above max interval")
+	case c.SubscriptionWeight < 0 || c.ProximityWeight < 0 || c.ChurnWeight < 0:
+		return fmt.Errorf("priority weights must not be negative")
+	case c.SubscriptionWeight+c.ProximityWeight+c.ChurnWeight <= 0:
+		return fmt.Errorf("at least one priority weight must be positive")
+	case c.SubscriptionSaturation <= 0 || c.ProximityHorizon <= 0 || c.ChurnSaturation <= 0:
+		return fmt.Errorf("saturation points must be positive")
+	case c.ChurnDecay < 0 || c.ChurnDecay > 1:
+		return fmt.Errorf("churn decay must be between 0 and 1")
+	case c.MaxConcurrentPerHost <= 0:
+		return fmt.Errorf("max concurrent checks per host must be positive")
+	case c.MaxWindow <= 0:
+		return fmt.Errorf("max window must be positive")
+	case c.BackoffBase <= 0 || c.BackoffMax < c.BackoffBase:
+		return fmt.Errorf("backoff must be positive with base not above max")
+	case c.BackoffJitter < 0 || c.BackoffJitter >= 1:
+		return fmt.Errorf("backoff jitter must be in [0, 1)")
+	}
+	return nil
+}
+
+type facilityState struct {
+	demand    repositories.FacilityDemand
+	nextCheck time.Time
+	failures  int
+	churn     float64
+	priority  float64
+}
+
+// hostState tracks an upstream host. Failures that are the host's fault back
+// off every facility on it, and busy keeps a host from being worked on by two
+// ticks at once.
+type hostState struct {
+	failures     int
+	blockedUntil time.Time
+	busy         bool
+}
+
+// UpstreamError marks a failed check as the upstream host's fault, such as
+// being rate limited or answering with a server error, rather than the
+// facility's. The whole host backs off when a check fails with one.
+type UpstreamError struct {
+	Err error
+}
+
+func (e *UpstreamError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+type Scheduler struct {
+	repo    repositories.IRepository
+	checker Checker
+	config  SchedulerConfig
+	clock   Clock
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	states    map[int]*facilityState
+	hosts     map[string]*hostState
+	heartbeat time.Time
+
+	// running counts the hosts being worked through.
+	running sync.WaitGroup
+}
+
+type SchedulerOption func(*Scheduler)
+
+func WithClock(clock Clock) SchedulerOption {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithRand replaces the jitter source, mostly so tests get repeatable
+// backoffs.
+func WithRand(r *rand.Rand) SchedulerOption {
+	return func(s *Scheduler) {
+		s.rand = r
+	}
+}
+
+func NewScheduler(repo repositories.IRepository, checker Checker, config SchedulerConfig, opts ...SchedulerOption) (*Scheduler, error) {
+	if repo == nil {
+		return nil, fmt.Errorf("repository is required to start a new scheduler")
+	}
+	if checker == nil {
+		return nil, fmt.Errorf("checker is required to start a new scheduler")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scheduler config | %w", err)
+	}
+
+	s := &Scheduler{
+		repo:    repo,
+		checker: checker,
+		config:  config,
+		clock:   realClock{},
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		states:  make(map[int]*facilityState),
+		hosts:   make(map[string]*hostState),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.heartbeat = s.clock.Now()
+
+	return s, nil
+}
+
+// Heartbeat returns when the scheduler last showed it was running: when it
+// started, finished a tick or finished a check within one.
+func (s *Scheduler) Heartbeat() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.heartbeat
+}
+
+func (s *Scheduler) beat() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.heartbeat = s.clock.Now()
+}
+
+// Run ticks until ctx is done and then waits for the checks under way.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.beat()
+	for {
+		if err := s.Tick(ctx); err != nil {
+			slog.ErrorContext(ctx, "Failed to run scheduler tick", "error", err)
+		}
+		s.beat()
+
+		select {
+		case <-ctx.Done():
+			s.Wait()
+			return ctx.Err()
+		case <-s.clock.After(s.config.TickInterval):
+		}
+	}
+}
+
+// Wait blocks until every check started by Tick finished.
+func (s *Scheduler) Wait() {
+	s.running.Wait()
+}
+
+// Tick refreshes demand from the database and starts checking every facility
+// that is due, highest priority first. Hosts are worked through independently
+// so a slow host doesn't hold up the others, and a host still busy from an
+// earlier tick or backing off is skipped.
+func (s *Scheduler) Tick(ctx context.Context) error {
+	demands, err := s.repo.GetFacilityDemands(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get facility demands | %w", err)
+	}
+
+	now := s.clock.Now()
+	s.refresh(demands, now)
+
+	for host, queue := range s.dueByHost(now) {
+		s.startHost(ctx, host, queue)
+	}
+
+	return nil
+}
+
+func (s *Scheduler) startHost(ctx context.Context, host string, queue []*facilityState) {
+	workers := s.config.MaxConcurrentPerHost
+	if len(queue) < workers {
+		workers = len(queue)
+	}
+
+	jobs := make(chan *facilityState, len(queue))
+	for _, state := range queue {
+		jobs <- state
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for state := range jobs {
+				if ctx.Err() != nil || s.hostBlocked(host) {
+					return
+				}
+				s.check(ctx, host, state)
+			}
+		}()
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		wg.Wait()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.hosts[host].busy = false
+	}()
+}
+
+func (s *Scheduler) hostBlocked(host string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clock.Now().Before(s.hosts[host].blockedUntil)
+}
+
+func (s *Scheduler) refresh(demands []repositories.FacilityDemand, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[int]bool, len(demands))
+	for _, demand := range demands {
+		seen[demand.Facility.Id] = true
+		state, ok := s.states[demand.Facility.Id]
+		if !ok {
+			state = &facilityState{nextCheck: now}
+			s.states[demand.Facility.Id] = state
+		}
+		state.demand = demand
+		state.priority = s.priority(state, now)
+	}
+
+	for id := range s.states {
+		if !seen[id] {
+			delete(s.states, id)
+		}
+	}
+}
+
+func (s *Scheduler) dueByHost(now time.Time) map[string][]*facilityState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*facilityState{}
+	for _, state := range s.states {
+		if !state.nextCheck.After(now) {
+			due = append(due, state)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].priority != due[j].priority {
+			return due[i].priority > due[j].priority
+		}
+		return due[i].demand.Facility.Id < due[j].demand.Facility.Id
+	})
+
+	byHost := make(map[string][]*facilityState)
+	for _, state := range due {
+		host := s.checker.Host(state.demand.Facility)
+		hs, ok := s.hosts[host]
+		if !ok {
+			hs = &hostState{}
+			s.hosts[host] = hs
+		}
+		if hs.busy || now.Before(hs.blockedUntil) {
+			continue
+		}
+		byHost[host] = append(byHost[host], state)
+	}
+	for host := range byHost {
+		s.hosts[host].busy = true
+	}
+
+	return byHost
+}
+
+func (s *Scheduler) check(ctx context.Context, host string, state *facilityState) {
+	s.mu.Lock()
+	facility := state.demand.Facility
+	from, to := s.window(state.demand, s.clock.Now())
+	s.mu.Unlock()
+
+	events, err := s.checker.Check(ctx, facility, from, to)
+	metrics.ObserveCheck(err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.heartbeat = now
+	hs := s.hosts[host]
+
+	var upstreamErr *UpstreamError
+	switch {
+	case errors.As(err, &upstreamErr):
+		// The facility is checked again as soon as its host has recovered.
+		hs.failures++
+		hs.blockedUntil = now.Add(s.backoff(hs.failures))
+		state.nextCheck = hs.blockedUntil
+		slog.ErrorContext(ctx, "Failed to check facility, backing off host", "facility_id", facility.Id, "host", host, "attempt", hs.failures, "until", hs.blockedUntil, "error", err)
+		return
+	case err != nil:
+		state.failures++
+		state.nextCheck = now.Add(s.backoff(state.failures))
+		slog.ErrorContext(ctx, "Failed to check facility", "facility_id", facility.Id, "attempt", state.failures, "error", err)
+		return
+	}
+	hs.failures = 0
+
+	changes := 0
+	for _, event := range events {
+		if event.Type == availability.EventNewlyAvailable || event.Type == availability.EventNewlyBooked {
+			changes++
+		}
+	}
+
+	state.failures = 0
+	state.churn = state.churn*s.config.ChurnDecay + float64(changes)
+	state.priority = s.priority(state, now)
+	state.nextCheck = now.Add(s.interval(state.priority))
+}
+
+// window checks from the earliest target date still ahead of us to the latest
+// one, capped by MaxWindow.
+func (s *Scheduler) window(demand repositories.FacilityDemand, now time.Time) (from, to time.Time) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	from, to = today, today.Add(s.config.MaxWindow)
+
+	if earliest, err := time.Parse(dateLayout, demand.EarliestTargetDate); err == nil && earliest.After(from) {
+		from = earliest
+	}
+	if latest, err := time.Parse(dateLayout, demand.LatestTargetDate); err == nil && latest.Before(to) {
+		to = latest
+	}
+	if to.Before(from) {
+		to = from
+	}
+
+	return from, to
+}
+
+func (s *Scheduler) priority(state *facilityState, now time.Time) float64 {
+	cfg := s.config
+
+	demand := math.Log1p(float64(state.demand.ActiveSubscriptions)) / math.Log1p(float64(cfg.SubscriptionSaturation))
+
+	proximity := 0.0
+	if earliest, err := time.Parse(dateLayout, state.demand.EarliestTargetDate); err == nil {
+		proximity = 1 - earliest.Sub(now).Seconds()/cfg.ProximityHorizon.Seconds()
+	}
+
+	churn := state.churn / cfg.ChurnSaturation
+
+	total := cfg.SubscriptionWeight + cfg.ProximityWeight + cfg.ChurnWeight
+	return (cfg.SubscriptionWeight*clamp(demand) + cfg.ProximityWeight*clamp(proximity) + cfg.ChurnWeight*clamp(churn)) / total
+}
+
+func (s *Scheduler) interval(priority float64) time.Duration {
+	span := s.config.MaxInterval - s.config.MinInterval
+	return s.config.MaxInterval - time.Duration(float64(span)*clamp(priority))
+}
+
+func (s *Scheduler) backoff(failures int) time.Duration {
+	backoff := s.config.BackoffMax
+	if failures < 32 {
+		if exp := s.config.BackoffBase << (failures - 1); exp > 0 && exp < backoff {
+			backoff = exp
+		}
+	}
+
+	jitter := 1 + s.config.BackoffJitter*(2*s.rand.Float64()-1)
+	return time.Duration(float64(backoff) * jitter)
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,324 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/availability"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+var schedulerStart = time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// demandRepository only answers GetFacilityDemands, the one thing the
+// scheduler reads.
+type demandRepository struct {
+	repositories.IRepository
+	demands []repositories.FacilityDemand
+}
+
+func (r *demandRepository) GetFacilityDemands(ctx context.Context) ([]repositories.FacilityDemand, error) {
+	return r.demands, nil
+}
+
+type fakeChecker struct {
+	mu      sync.Mutex
+	hosts   map[int]string
+	errs    map[int]error
+	block   map[int]chan struct{}
+	checked []int
+
+	done chan int
+}
+
+func newFakeChecker() *fakeChecker {
+	return &fakeChecker{
+		hosts: make(map[int]string),
+		errs:  make(map[int]error),
+		block: make(map[int]chan struct{}),
+		done:  make(chan int, 100),
+	}
+}
+
+func (c *fakeChecker) Check(ctx context.Context, facility repositories.Facility, from, to time.Time) ([]availability.ChangeEvent, error) {
+	c.mu.Lock()
+	block := c.block[facility.Id]
+	c.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checked = append(c.checked, facility.Id)
+	c.done <- facility.Id
+	return nil, c.errs[facility.Id]
+}
+
+func (c *fakeChecker) Host(facility repositories.Facility) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if host, ok := c.hosts[facility.Id]; ok {
+		return host
+	}
+	return "default"
+}
+
+func (c *fakeChecker) setErr(facilityId int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs[facilityId] = err
+}
+
+// take returns the facilities checked since the last call.
+func (c *fakeChecker) take() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	checked := c.checked
+	c.checked = nil
+	return checked
+}
+
+func demand(facilityId, subscriptions int) repositories.FacilityDemand {
+	return repositories.FacilityDemand{
+		Facility:            repositories.Facility{Id: facilityId},
+		ActiveSubscriptions: subscriptions,
+	}
+}
+
+func newTestScheduler(t *testing.T, checker Checker, demands ...repositories.FacilityDemand) (*Scheduler, *fakeClock) {
+	t.Helper()
+
+	config := DefaultSchedulerConfig()
+	config.MaxConcurrentPerHost = 1
+	config.BackoffJitter = 0
+
+	clock := newFakeClock(schedulerStart)
+	s, err := NewScheduler(&demandRepository{demands: demands}, checker, config, WithClock(clock), WithRand(rand.New(rand.NewSource(1))))
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+
+	return s, clock
+}
+
+func tick(t *testing.T, s *Scheduler) {
+	t.Helper()
+	if err := s.Tick(context.Background()); err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	s.Wait()
+}
+
+func TestSchedulerChecksHighestPriorityFirst(t *testing.T) {
+	checker := newFakeChecker()
+	s, _ := newTestScheduler(t, checker, demand(1, 0), demand(2, 20), demand(3, 5))
+
+	tick(t, s)
+
+	if got, want := checker.take(), []int{2, 3, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checked %v, want %v", got, want)
+	}
+}
+
+func TestSchedulerChecksBusyFacilitiesMoreOften(t *testing.T) {
+	checker := newFakeChecker()
+	s, clock := newTestScheduler(t, checker, demand(1, 0), demand(2, 20))
+
+	tick(t, s)
+	checker.take()
+
+	// Facility 2 is at full subscription demand, half the priority, so it is
+	// checked every 16 minutes. Facility 1 has none and waits the full 30.
+	clock.Advance(15 * time.Minute)
+	tick(t, s)
+	if got := checker.take(); len(got) != 0 {
+		t.Errorf("checked %v after 15m, want nothing", got)
+	}
+
+	clock.Advance(2 * time.Minute)
+	tick(t, s)
+	if got, want := checker.take(), []int{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checked %v after 17m, want %v", got, want)
+	}
+
+	clock.Advance(14 * time.Minute)
+	tick(t, s)
+	if got, want := checker.take(), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checked %v after 31m, want %v", got, want)
+	}
+}
+
+func TestSchedulerBacksOffHostOnUpstreamErrors(t *testing.T) {
+	checker := newFakeChecker()
+	checker.hosts[1], checker.hosts[2], checker.hosts[3] = "a", "a", "b"
+	checker.errs[1] = &UpstreamError{Err: errors.New("429 Too Many Requests")}
+	s, clock := newTestScheduler(t, checker, demand(1, 20), demand(2, 10), demand(3, 0))
+
+	// Facility 1 is rate limited, so facility 2 on the same host must wait
+	// while host b carries on.
+	tick(t, s)
+	if got, want := checker.take(), []int{1, 3}; !sameIds(got, want) {
+		t.Errorf("checked %v, want %v", got, want)
+	}
+
+	clock.Advance(30 * time.Second)
+	tick(t, s)
+	if got := checker.take(); len(got) != 0 {
+		t.Errorf("checked %v while host a backs off, want nothing", got)
+	}
+
+	// Past the one minute backoff host a is tried again, and a second
+	// failure doubles the backoff.
+	clock.Advance(31 * time.Second)
+	tick(t, s)
+	if got, want := checker.take(), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checked %v after backoff, want %v", got, want)
+	}
+
+	clock.Advance(time.Minute + time.Second)
+	tick(t, s)
+	if got := checker.take(); len(got) != 0 {
+		t.Errorf("checked %v within doubled backoff, want nothing", got)
+	}
+
+	checker.setErr(1, nil)
+	clock.Advance(time.Minute)
+	tick(t, s)
+	if got, want := checker.take(), []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checked %v once host a recovered, want %v", got, want)
+	}
+}
+
+func TestSchedulerBacksOffOnlyFacilityOnOtherErrors(t *testing.T) {
+	checker := newFakeChecker()
+	checker.errs[1] = errors.New("facility not found upstream")
+	s, clock := newTestScheduler(t, checker, demand(1, 20), demand(2, 0))
+
+	tick(t, s)
+	if got, want := checker.take(), []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checked %v, want %v", got, want)
+	}
+
+	clock.Advance(time.Minute)
+	tick(t, s)
+	if got, want := checker.take(), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checked %v after facility backoff, want %v", got, want)
+	}
+}
+
+func TestSchedulerSlowHostDoesNotStallOthers(t *testing.T) {
+	checker := newFakeChecker()
+	checker.hosts[1], checker.hosts[2] = "slow", "fast"
+	release := make(chan struct{})
+	checker.block[1] = release
+	s, clock := newTestScheduler(t, checker, demand(1, 20), demand(2, 0))
+	defer func() {
+		close(release)
+		s.Wait()
+	}()
+
+	if err := s.Tick(context.Background()); err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	waitForCheck(t, checker, 2)
+	waitForIdleHost(t, s, "fast")
+
+	// The slow host is still busy with the first tick, so only the fast one
+	// is worked on again.
+	clock.Advance(31 * time.Minute)
+	if err := s.Tick(context.Background()); err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	waitForCheck(t, checker, 2)
+
+	release <- struct{}{}
+	waitForCheck(t, checker, 1)
+	checker.mu.Lock()
+	checker.block[1] = nil
+	checker.mu.Unlock()
+
+	if got, want := checker.take(), []int{2, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checked %v, want %v", got, want)
+	}
+}
+
+func TestSchedulerRunStopsWhenCancelled(t *testing.T) {
+	checker := newFakeChecker()
+	s, clock := newTestScheduler(t, checker, demand(1, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+
+	waitForCheck(t, checker, 1)
+	if heartbeat := s.Heartbeat(); !heartbeat.Equal(clock.Now()) {
+		t.Errorf("heartbeat = %v, want %v", heartbeat, clock.Now())
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after cancel")
+	}
+}
+
+func waitForCheck(t *testing.T, checker *fakeChecker, facilityId int) {
+	t.Helper()
+
+	select {
+	case id := <-checker.done:
+		if id != facilityId {
+			t.Fatalf("checked facility %d, want %d", id, facilityId)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("facility %d wasn't checked", facilityId)
+	}
+}
+
+func waitForIdleHost(t *testing.T, s *Scheduler, host string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		busy := s.hosts[host].busy
+		s.mu.Unlock()
+		if !busy {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("host %s is still busy", host)
+}
+
+// sameIds compares ids regardless of order, for checks on different hosts.
+func sameIds(got, want []int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	counts := make(map[int]int)
+	for _, id := range got {
+		counts[id]++
+	}
+	for _, id := range want {
+		counts[id]--
+	}
+	for _, count := range counts {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
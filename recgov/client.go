@@ -0,0 +1,338 @@
+package recgov
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://www.recreation.gov"
+
+	monthPath       = "/api/camps/availability/campground/%s/month"
+	startDateLayout = "2006-01-02T00:00:00.000Z"
+	dateLayout      = "2006-01-02"
+)
+
+var ErrRateLimited = errors.New("rate limited by upstream")
+
+// StatusError is returned when upstream answers with a non 2xx status that
+// is not worth retrying, or keeps failing after every retry.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+type Campsite struct {
+	CampsiteId          string            `json:"campsite_id"`
+	Site                string            `json:"site"`
+	Loop                string            `json:"loop"`
+	CampsiteReserveType string            `json:"campsite_reserve_type"`
+	CampsiteType        string            `json:"campsite_type"`
+	TypeOfUse           string            `json:"type_of_use"`
+	MinNumPeople        int               `json:"min_num_people"`
+	MaxNumPeople        int               `json:"max_num_people"`
+	Availabilities      map[string]string `json:"availabilities"`
+	Quantities          map[string]int    `json:"quantities"`
+}
+
+type MonthResponse struct {
+	Campsites map[string]Campsite `json:"campsites"`
+}
+
+// Availability is one campsite on one day.
+type Availability struct {
+	Campsite Campsite
+	Date     string
+	Status   string
+}
+
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	limiter      *limiter
+	maxRetries   int
+	retryWait    time.Duration
+	maxRetryWait time.Duration
+	userAgent    string
+}
+
+type Option func(*Client)
+
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRateLimit spaces consecutive requests at least interval apart.
+func WithRateLimit(interval time.Duration) Option {
+	return func(c *Client) {
+		c.limiter.interval = interval
+	}
+}
+
+// WithRetries retries failed requests up to maxRetries times, doubling wait
+// between attempts unless upstream sends a Retry-After.
+func WithRetries(maxRetries int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryWait = wait
+	}
+}
+
+// WithMaxRetryWait caps the wait between attempts. A request upstream asks to
+// wait longer for isn't retried, leaving it to the caller to back off.
+func WithMaxRetryWait(maxWait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetryWait = maxWait
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		baseURL:      DefaultBaseURL,
+		httpClient:   &http.Client{Timeout: 15 * time.Second},
+		limiter:      &limiter{interval: time.Second},
+		maxRetries:   3,
+		retryWait:    time.Second,
+		maxRetryWait: 30 * time.Second,
+		userAgent:    "lantrn-api-go",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Host is the upstream host, used to cap concurrent requests per upstream.
+func (c *Client) Host() string {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return c.baseURL
+	}
+	return u.Host
+}
+
+// GetMonth fetches availability of every campsite in a facility for the
+// month containing month.
+func (c *Client) GetMonth(ctx context.Context, facilityId string, month time.Time) (*MonthResponse, error) {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	query := url.Values{}
+	query.Set("start_date", start.Format(startDateLayout))
+	endpoint := c.baseURL + fmt.Sprintf(monthPath, url.PathEscape(facilityId)) + "?" + query.Encode()
+
+	body, err := c.get(ctx, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get month %s for facility %s | %w", start.Format("2006-01"), facilityId, err)
+	}
+
+	var response MonthResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse month %s for facility %s | %w", start.Format("2006-01"), facilityId, err)
+	}
+
+	return &response, nil
+}
+
+// GetAvailability fetches every month overlapping [from, to] and flattens it
+// into one entry per campsite and day within the range.
+func (c *Client) GetAvailability(ctx context.Context, facilityId string, from, to time.Time) ([]Availability, error) {
+	fromDate, toDate := from.UTC().Format(dateLayout), to.UTC().Format(dateLayout)
+
+	availabilities := []Availability{}
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !month.After(to) {
+		response, err := c.GetMonth(ctx, facilityId, month)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, campsite := range response.Campsites {
+			for timestamp, status := range campsite.Availabilities {
+				date, err := parseDate(timestamp)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse availability date %q | %w", timestamp, err)
+				}
+				if date < fromDate || date > toDate {
+					continue
+				}
+				availabilities = append(availabilities, Availability{
+					Campsite: campsite,
+					Date:     date,
+					Status:   status,
+				})
+			}
+		}
+
+		month = month.AddDate(0, 1, 0)
+	}
+
+	sort.Slice(availabilities, func(i, j int) bool {
+		if availabilities[i].Date != availabilities[j].Date {
+			return availabilities[i].Date < availabilities[j].Date
+		}
+		return availabilities[i].Campsite.CampsiteId < availabilities[j].Campsite.CampsiteId
+	})
+
+	return availabilities, nil
+}
+
+func (c *Client) get(ctx context.Context, endpoint string) ([]byte, error) {
+	wait := c.retryWait
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			wait *= 2
+			if wait > c.maxRetryWait {
+				wait = c.maxRetryWait
+			}
+		}
+
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		body, retryAfter, err := c.do(ctx, endpoint)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+
+		if !retryable(err) {
+			return nil, err
+		}
+		if retryAfter > c.maxRetryWait {
+			return nil, fmt.Errorf("upstream asked to wait %s | %w", retryAfter.Round(time.Second), err)
+		}
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+	}
+
+	return nil, fmt.Errorf("giving up after %d attempts | %w", c.maxRetries+1, lastErr)
+}
+
+func (c *Client) do(ctx context.Context, endpoint string) (body []byte, retryAfter time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to build request | %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to send request | %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response | %w", err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("%w | %v", ErrRateLimited, &StatusError{StatusCode: resp.StatusCode, Body: truncate(body)})
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), &StatusError{StatusCode: resp.StatusCode, Body: truncate(body)}
+	}
+
+	return body, 0, nil
+}
+
+// Temporary reports whether err is upstream failing or limiting requests, as
+// opposed to a problem with the request itself, so trying again later may
+// succeed.
+func Temporary(err error) bool {
+	return retryable(err)
+}
+
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+
+	// Anything else is a transport error.
+	return true
+}
+
+func parseDate(timestamp string) (string, error) {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format(dateLayout), nil
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func truncate(body []byte) string {
+	const max = 200
+	if len(body) > max {
+		return string(body[:max]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,261 @@
+package recgov
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/recgov/recgovtest"
+)
+
+func newTestClient(server *httptest.Server, opts ...Option) *Client {
+	opts = append([]Option{
+		WithBaseURL(server.URL),
+		WithRateLimit(0),
+		WithRetries(2, time.Millisecond),
+	}, opts...)
+	return NewClient(opts...)
+}
+
+func TestGetAvailabilityParsesMonths(t *testing.T) {
+	server := recgovtest.NewServer()
+	defer server.Close()
+	client := newTestClient(server.Server)
+
+	from := time.Date(2023, 7, 30, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 8, 2, 0, 0, 0, 0, time.UTC)
+	availabilities, err := client.GetAvailability(context.Background(), "232447", from, to)
+	if err != nil {
+		t.Fatalf("GetAvailability: %v", err)
+	}
+
+	// Four campsites over four days, across both monthly fixtures.
+	if len(availabilities) != 16 {
+		t.Fatalf("got %d availabilities, want 16", len(availabilities))
+	}
+	for idx := 1; idx < len(availabilities); idx++ {
+		prev, curr := availabilities[idx-1], availabilities[idx]
+		if prev.Date > curr.Date || (prev.Date == curr.Date && prev.Campsite.CampsiteId >= curr.Campsite.CampsiteId) {
+			t.Fatalf("availabilities not ordered by date then campsite at %d: %+v then %+v", idx, prev, curr)
+		}
+	}
+
+	// Campsite IDs are compared as upstream's strings.
+	first := availabilities[0]
+	if first.Date != "2023-07-30" || first.Campsite.CampsiteId != "146" || first.Status != "Reserved" {
+		t.Errorf("first availability = %s %s %s, want 2023-07-30 146 Reserved", first.Date, first.Campsite.CampsiteId, first.Status)
+	}
+	if first.Campsite.Site != "077" || first.Campsite.Loop != "Upper Pines" || first.Campsite.CampsiteType != "TENT ONLY NONELECTRIC" || first.Campsite.MaxNumPeople != 6 {
+		t.Errorf("first campsite = %+v, want tent only site 077 in Upper Pines for 6", first.Campsite)
+	}
+	if last := availabilities[len(availabilities)-1]; last.Date != "2023-08-02" {
+		t.Errorf("last availability date = %s, want 2023-08-02", last.Date)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("made %d requests, want one per month: %v", len(requests), requests)
+	}
+	if want := "/api/camps/availability/campground/232447/month?start_date=2023-07-01T00%3A00%3A00.000Z"; requests[0] != want {
+		t.Errorf("first request = %s, want %s", requests[0], want)
+	}
+}
+
+func TestGetAvailabilityWithoutFixture(t *testing.T) {
+	server := recgovtest.NewServer()
+	defer server.Close()
+	client := newTestClient(server.Server)
+
+	from := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	availabilities, err := client.GetAvailability(context.Background(), "999999", from, from.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("GetAvailability: %v", err)
+	}
+	if len(availabilities) != 0 {
+		t.Errorf("got %d availabilities, want none", len(availabilities))
+	}
+}
+
+func TestGetMonthRetriesServerErrors(t *testing.T) {
+	server := recgovtest.NewServer()
+	defer server.Close()
+	client := newTestClient(server.Server)
+
+	server.FailNext(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	response, err := client.GetMonth(context.Background(), "232450", time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetMonth: %v", err)
+	}
+	if len(response.Campsites) != 2 {
+		t.Errorf("got %d campsites, want 2", len(response.Campsites))
+	}
+	if requests := server.Requests(); len(requests) != 3 {
+		t.Errorf("made %d requests, want 3", len(requests))
+	}
+}
+
+func TestGetMonthGivesUpAfterRetries(t *testing.T) {
+	server := recgovtest.NewServer()
+	defer server.Close()
+	client := newTestClient(server.Server)
+
+	server.FailNext(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	_, err := client.GetMonth(context.Background(), "232450", time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC))
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("GetMonth error = %v, want a 502 StatusError", err)
+	}
+	if !Temporary(err) {
+		t.Errorf("Temporary(%v) = false, want true", err)
+	}
+	if requests := server.Requests(); len(requests) != 3 {
+		t.Errorf("made %d requests, want 3", len(requests))
+	}
+}
+
+func TestGetMonthDoesNotRetryClientErrors(t *testing.T) {
+	server := recgovtest.NewServer()
+	defer server.Close()
+	client := newTestClient(server.Server)
+
+	server.FailNext(http.StatusNotFound)
+	_, err := client.GetMonth(context.Background(), "232450", time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC))
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("GetMonth error = %v, want a 404 StatusError", err)
+	}
+	if Temporary(err) {
+		t.Errorf("Temporary(%v) = true, want false", err)
+	}
+	if requests := server.Requests(); len(requests) != 1 {
+		t.Errorf("made %d requests, want 1", len(requests))
+	}
+}
+
+func TestGetMonthHonoursRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"campsites":{}}`))
+	}))
+	defer server.Close()
+	client := newTestClient(server)
+
+	start := time.Now()
+	if _, err := client.GetMonth(context.Background(), "232450", time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("GetMonth: %v", err)
+	}
+
+	// The client's own wait is a millisecond, so anything near a second came
+	// from the header.
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least the 1s Retry-After", elapsed)
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 2 {
+		t.Errorf("made %d requests, want 2", calls)
+	}
+}
+
+func TestGetMonthDoesNotWaitOutLongRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "3600")
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	client := newTestClient(server, WithMaxRetryWait(time.Second))
+
+	start := time.Now()
+	_, err := client.GetMonth(context.Background(), "232450", time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("GetMonth error = %v, want ErrRateLimited", err)
+	}
+	if !Temporary(err) {
+		t.Errorf("Temporary(%v) = false, want true so the caller backs off", err)
+	}
+
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("gave up after %v, want no wait for an hour long Retry-After", elapsed)
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 1 {
+		t.Errorf("made %d requests, want 1", calls)
+	}
+}
+
+func TestGetMonthStopsRetryingWhenCancelled(t *testing.T) {
+	server := recgovtest.NewServer()
+	defer server.Close()
+	client := newTestClient(server.Server, WithRetries(3, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	server.FailNext(http.StatusServiceUnavailable)
+	_, err := client.GetMonth(ctx, "232450", time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetMonth error = %v, want context.DeadlineExceeded", err)
+	}
+	if Temporary(err) {
+		t.Errorf("Temporary(%v) = true, want false", err)
+	}
+}
+
+func TestRateLimitSpacesRequests(t *testing.T) {
+	server := recgovtest.NewServer()
+	defer server.Close()
+
+	const interval = 50 * time.Millisecond
+	client := newTestClient(server.Server, WithRateLimit(interval))
+
+	// The limiter is shared, so concurrent callers queue up too.
+	start := time.Now()
+	errs := make(chan error, 4)
+	for idx := 0; idx < 4; idx++ {
+		go func() {
+			_, err := client.GetMonth(context.Background(), "232450", time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC))
+			errs <- err
+		}()
+	}
+	for idx := 0; idx < 4; idx++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("GetMonth: %v", err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 3*interval {
+		t.Errorf("4 requests took %v, want at least %v", elapsed, 3*interval)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "5", want: 5 * time.Second},
+		{value: "soon", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got <= 0 || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %v, want up to a minute", date, got)
+	}
+}
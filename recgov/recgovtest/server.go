@@ -0,0 +1,119 @@
+// Package recgovtest serves recorded recreation.gov availability responses
+// over httptest so the client and the poller can be exercised without
+// network access.
+package recgovtest
+
+import (
+	"embed"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"sync"
+	"time"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+var monthPathPattern = regexp.MustCompile(`^/api/camps/availability/campground/([^/]+)/month$`)
+
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	overrides map[string][]byte
+	failures  []int
+	requests  []string
+}
+
+// NewServer starts a server answering the monthly campground availability
+// endpoint from the embedded fixtures, named <facilityId>_<yyyy-mm>.json.
+// Months without a fixture come back with no campsites, like upstream does
+// for campgrounds that are not open yet.
+func NewServer() *Server {
+	s := &Server{
+		overrides: make(map[string][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// SetMonth replaces the response for a facility and month, e.g. to simulate
+// a cancellation between two polls.
+func (s *Server) SetMonth(facilityId string, month time.Time, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[fixtureName(facilityId, month)] = body
+}
+
+// FailNext makes the next len(statuses) requests fail with the given statuses
+// in order.
+func (s *Server) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// Requests returns the request URIs received so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL.RequestURI())
+	var failure int
+	if len(s.failures) > 0 {
+		failure, s.failures = s.failures[0], s.failures[1:]
+	}
+	s.mu.Unlock()
+
+	if failure != 0 {
+		if failure == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		http.Error(w, http.StatusText(failure), failure)
+		return
+	}
+
+	matches := monthPathPattern.FindStringSubmatch(r.URL.Path)
+	if r.Method != http.MethodGet || matches == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	month, err := time.Parse("2006-01-02T00:00:00.000Z", r.URL.Query().Get("start_date"))
+	if err != nil || month.Day() != 1 {
+		http.Error(w, `{"error":"invalid start_date"}`, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.month(matches[1], month))
+}
+
+func (s *Server) month(facilityId string, month time.Time) []byte {
+	name := fixtureName(facilityId, month)
+
+	s.mu.Lock()
+	body, ok := s.overrides[name]
+	s.mu.Unlock()
+	if ok {
+		return body
+	}
+
+	body, err := fixtures.ReadFile(path.Join("fixtures", name))
+	if err != nil {
+		return []byte(`{"campsites":{}}`)
+	}
+
+	return body
+}
+
+func fixtureName(facilityId string, month time.Time) string {
+	return fmt.Sprintf("%s_%s.json", facilityId, month.Format("2006-01"))
+}
@@ -0,0 +1,332 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/katakeda/lantrn-api-go/errs"
+)
+
+const (
+	dateLayout          = "2006-01-02"
+	defaultCalendarDays = 30
+	maxCalendarDays     = 366
+)
+
+const (
+	AvailabilityStatusAvailable    = "Available"
+	AvailabilityStatusReserved     = "Reserved"
+	AvailabilityStatusNotAvailable = "Not Available"
+)
+
+type AvailabilitySnapshot struct {
+	Id         int       `json:"id" db:"id"`
+	FacilityId int       `json:"facilityId" db:"facility_id"`
+	CampsiteId *int      `json:"campsiteId" db:"campsite_id"`
+	Date       string    `json:"date" db:"date"`
+	Status     string    `json:"status" db:"status"`
+	ObservedAt time.Time `json:"observedAt" db:"observed_at"`
+}
+
+type AvailabilityDay struct {
+	Date      string                 `json:"date"`
+	Available int                    `json:"available"`
+	Campsites []AvailabilitySnapshot `json:"campsites"`
+}
+
+type AvailabilityOpening struct {
+	CampsiteId *int      `json:"campsiteId"`
+	Date       string    `json:"date"`
+	OpenedAt   time.Time `json:"openedAt"`
+	LeadDays   int       `json:"leadDays"`
+}
+
+type AvailabilityHistorySummary struct {
+	Openings       int      `json:"openings"`
+	MedianLeadDays *float64 `json:"medianLeadDays"`
+}
+
+type GetAvailabilityFilter struct {
+	FacilityId string
+	From       string
+	To         string
+}
+
+type GetAvailabilityResponse struct {
+	Data     []AvailabilityDay `json:"data"`
+	Metadata GetMetadata       `json:"metadata"`
+}
+
+type GetAvailabilityHistoryResponse struct {
+	Data     []AvailabilityOpening      `json:"data"`
+	Summary  AvailabilityHistorySummary `json:"summary"`
+	Metadata GetMetadata                `json:"metadata"`
+}
+
+type CreateAvailabilitySnapshotPayload struct {
+	FacilityId int
+	CampsiteId *int
+	Date       string
+	Status     string
+	ObservedAt time.Time
+}
+
+func (r *Repository) GetAvailability(ctx context.Context, filter GetAvailabilityFilter) (response *GetAvailabilityResponse, err error) {
+	snapshots, err := r.GetLatestAvailabilitySnapshots(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest availability snapshots | %w", err)
+	}
+
+	calendar := buildAvailabilityCalendar(snapshots)
+
+	return &GetAvailabilityResponse{
+		Data: calendar,
+		// Calendars and histories are never split, they are the first page.
+		Metadata: newMetadata(1, len(calendar), len(calendar)),
+	}, nil
+}
+
+func (r *Repository) GetLatestAvailabilitySnapshots(ctx context.Context, filter GetAvailabilityFilter) (snapshots []AvailabilitySnapshot, err error) {
+	db := r.reader(ctx)
+
+	from, to, err := parseDateRange(filter.From, filter.To)
+	if err != nil {
+		return nil, errs.InvalidArgument("from and to must be a valid date range", err)
+	}
+
+	// Only the most recent observation for each campsite and date is the
+	// current state, everything before it is history.
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select(availabilitySnapshotCols()...).
+		Options(`DISTINCT ON (campsite_id, "availability_snapshot"."date")`).
+		From(`"availability_snapshot"`).
+		Where(sq.Eq{"facility_id": filter.FacilityId}).
+		Where(sq.GtOrEq{"date": from}).
+		Where(sq.LtOrEq{"date": to}).
+		OrderBy("campsite_id", `"availability_snapshot"."date"`, "observed_at DESC")
+
+	sqlStmt, sqlArgs, err := psql.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	if err := pgxscan.ScanAll(&snapshots, rows); err != nil {
+		return nil, fmt.Errorf("failed to scan rows | %w", err)
+	}
+
+	return snapshots, nil
+}
+
+func (r *Repository) GetAvailabilityHistory(ctx context.Context, filter GetAvailabilityFilter) (response *GetAvailabilityHistoryResponse, err error) {
+	db := r.reader(ctx)
+
+	from, to, err := parseDateRange(filter.From, filter.To)
+	if err != nil {
+		return nil, errs.InvalidArgument("from and to must be a valid date range", err)
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select(availabilitySnapshotCols()...).
+		From(`"availability_snapshot"`).
+		Where(sq.Eq{"facility_id": filter.FacilityId}).
+		Where(sq.GtOrEq{"date": from}).
+		Where(sq.LtOrEq{"date": to}).
+		OrderBy("campsite_id", `"availability_snapshot"."date"`, "observed_at")
+
+	sqlStmt, sqlArgs, err := psql.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	var snapshots []AvailabilitySnapshot
+	if err := pgxscan.ScanAll(&snapshots, rows); err != nil {
+		return nil, fmt.Errorf("failed to scan rows | %w", err)
+	}
+
+	openings := findAvailabilityOpenings(snapshots)
+
+	return &GetAvailabilityHistoryResponse{
+		Data:    openings,
+		Summary: summarizeAvailabilityOpenings(openings),
+		// Calendars and histories are never split, they are the first page.
+		Metadata: newMetadata(1, len(openings), len(openings)),
+	}, nil
+}
+
+func (r *Repository) CreateAvailabilitySnapshots(ctx context.Context, payloads []CreateAvailabilitySnapshotPayload) (err error) {
+	if len(payloads) <= 0 {
+		return nil
+	}
+
+	tx, endTxn, err := r.getTxn(ctx)
+	if err != nil {
+		return err
+	}
+	defer endTxn(&err)
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Insert(`"availability_snapshot"`).
+		Columns("facility_id", "campsite_id", "date", "status", "observed_at")
+
+	for _, payload := range payloads {
+		observedAt := payload.ObservedAt
+		if observedAt.IsZero() {
+			observedAt = time.Now()
+		}
+		psql = psql.Values(payload.FacilityId, payload.CampsiteId, payload.Date, payload.Status, observedAt)
+	}
+
+	sqlStmt, sqlArgs, err := psql.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	if _, err := tx.Exec(ctx, sqlStmt, sqlArgs...); err != nil {
+		return fmt.Errorf("failed to execute: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	return nil
+}
+
+// availabilitySnapshotCols selects date as text since pgx can't scan a date
+// column into a string. Queries order by the qualified column so they can use
+// its index rather than the text.
+func availabilitySnapshotCols() []string {
+	return []string{
+		"id",
+		"facility_id",
+		"campsite_id",
+		`to_char("date", 'YYYY-MM-DD') AS "date"`,
+		"status",
+		"observed_at",
+	}
+}
+
+func parseDateRange(fromStr, toStr string) (from, to string, err error) {
+	fromDate := time.Now().UTC().Truncate(24 * time.Hour)
+	if fromStr != "" {
+		fromDate, err = time.Parse(dateLayout, fromStr)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid from date %q | %w", fromStr, err)
+		}
+	}
+
+	toDate := fromDate.AddDate(0, 0, defaultCalendarDays)
+	if toStr != "" {
+		toDate, err = time.Parse(dateLayout, toStr)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid to date %q | %w", toStr, err)
+		}
+	}
+
+	if toDate.Before(fromDate) {
+		return "", "", fmt.Errorf("to date %s is before from date %s", toDate.Format(dateLayout), fromDate.Format(dateLayout))
+	}
+	if toDate.Sub(fromDate) > maxCalendarDays*24*time.Hour {
+		return "", "", fmt.Errorf("date range exceeds %d days", maxCalendarDays)
+	}
+
+	return fromDate.Format(dateLayout), toDate.Format(dateLayout), nil
+}
+
+func buildAvailabilityCalendar(snapshots []AvailabilitySnapshot) []AvailabilityDay {
+	daysMap := make(map[string]*AvailabilityDay)
+	for idx := range snapshots {
+		snapshot := snapshots[idx]
+		day, ok := daysMap[snapshot.Date]
+		if !ok {
+			day = &AvailabilityDay{Date: snapshot.Date}
+			daysMap[snapshot.Date] = day
+		}
+		if snapshot.Status == AvailabilityStatusAvailable {
+			day.Available++
+		}
+		day.Campsites = append(day.Campsites, snapshot)
+	}
+
+	calendar := make([]AvailabilityDay, 0, len(daysMap))
+	for _, day := range daysMap {
+		calendar = append(calendar, *day)
+	}
+	sort.Slice(calendar, func(i, j int) bool {
+		return calendar[i].Date < calendar[j].Date
+	})
+
+	return calendar
+}
+
+// findAvailabilityOpenings expects snapshots ordered by campsite, date and
+// observation time, and reports every point where a site went from anything
+// else to available. A site that was available the first time it was seen is
+// not counted since we can't tell when it opened.
+func findAvailabilityOpenings(snapshots []AvailabilitySnapshot) []AvailabilityOpening {
+	openings := []AvailabilityOpening{}
+	for idx := 1; idx < len(snapshots); idx++ {
+		prev, curr := snapshots[idx-1], snapshots[idx]
+		if !sameCampsite(prev.CampsiteId, curr.CampsiteId) || prev.Date != curr.Date {
+			continue
+		}
+		if prev.Status == AvailabilityStatusAvailable || curr.Status != AvailabilityStatusAvailable {
+			continue
+		}
+
+		date, err := time.Parse(dateLayout, curr.Date)
+		if err != nil {
+			continue
+		}
+		observed := curr.ObservedAt.UTC().Truncate(24 * time.Hour)
+
+		openings = append(openings, AvailabilityOpening{
+			CampsiteId: curr.CampsiteId,
+			Date:       curr.Date,
+			OpenedAt:   curr.ObservedAt,
+			LeadDays:   int(date.Sub(observed).Hours() / 24),
+		})
+	}
+
+	return openings
+}
+
+func summarizeAvailabilityOpenings(openings []AvailabilityOpening) AvailabilityHistorySummary {
+	summary := AvailabilityHistorySummary{Openings: len(openings)}
+	if len(openings) <= 0 {
+		return summary
+	}
+
+	leadDays := make([]int, len(openings))
+	for idx := range openings {
+		leadDays[idx] = openings[idx].LeadDays
+	}
+	sort.Ints(leadDays)
+
+	mid := len(leadDays) / 2
+	median := float64(leadDays[mid])
+	if len(leadDays)%2 == 0 {
+		median = math.Round(float64(leadDays[mid-1]+leadDays[mid])/2*10) / 10
+	}
+	summary.MedianLeadDays = &median
+
+	return summary
+}
+
+func sameCampsite(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+type Campsite struct {
+	Id               int       `json:"id" db:"id"`
+	Name             string    `json:"name" db:"name"`
+	SiteType         *string   `json:"siteType" db:"site_type"`
+	MaxOccupancy     *int      `json:"maxOccupancy" db:"max_occupancy"`
+	EquipmentAllowed []string  `json:"equipmentAllowed" db:"equipment_allowed"`
+	Loop             *string   `json:"loop" db:"loop"`
+	CampsiteId       string    `json:"campsiteId" db:"campsite_id"`
+	FacilityId       int       `json:"facilityId" db:"facility_id"`
+	CreatedAt        time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt        time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type GetCampsitesFilter struct {
+	FacilityId string
+	SiteType   string
+	Loop       string
+	Page       string
+}
+
+type UpsertCampsitePayload struct {
+	Name         string
+	SiteType     *string
+	MaxOccupancy *int
+	Loop         *string
+	CampsiteId   string
+	FacilityId   int
+}
+
+type GetCampsitesResponse struct {
+	Data     []Campsite  `json:"data"`
+	Metadata GetMetadata `json:"metadata"`
+}
+
+func (r *Repository) GetCampsites(ctx context.Context, filter GetCampsitesFilter) (response *GetCampsitesResponse, err error) {
+	db := r.reader(ctx)
+
+	countSql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select("COUNT(*)").
+		From(`"campsite"`)
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select(campsiteCols()...).
+		From(`"campsite"`).
+		OrderBy("loop", "name").
+		Limit(uint64(r.defaultPerPage))
+
+	if filter.FacilityId != "" {
+		countSql = countSql.Where(sq.Eq{"facility_id": filter.FacilityId})
+		psql = psql.Where(sq.Eq{"facility_id": filter.FacilityId})
+	}
+
+	if filter.SiteType != "" {
+		countSql = countSql.Where(sq.Eq{"site_type": filter.SiteType})
+		psql = psql.Where(sq.Eq{"site_type": filter.SiteType})
+	}
+
+	if filter.Loop != "" {
+		countSql = countSql.Where(sq.Eq{"loop": filter.Loop})
+		psql = psql.Where(sq.Eq{"loop": filter.Loop})
+	}
+
+	page, err := parsePage(filter.Page)
+	if err != nil {
+		return nil, err
+	}
+	if page > 1 {
+		psql = psql.Offset(uint64((page - 1) * r.defaultPerPage))
+	}
+
+	var totalCnt int
+	{
+		sqlStmt, sqlArgs, err := countSql.ToSql()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+		}
+		rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+		}
+		if err := pgxscan.ScanOne(&totalCnt, rows); err != nil {
+			return nil, fmt.Errorf("failed to scan rows | %w", err)
+		}
+	}
+
+	campsites := []Campsite{}
+	{
+		sqlStmt, sqlArgs, err := psql.ToSql()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+		}
+		rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+		}
+		if err := pgxscan.ScanAll(&campsites, rows); err != nil {
+			return nil, fmt.Errorf("failed to scan rows | %w", err)
+		}
+	}
+
+	return &GetCampsitesResponse{
+		Data:     campsites,
+		Metadata: newMetadata(page, r.defaultPerPage, totalCnt),
+	}, nil
+}
+
+// GetFacilityCampsites returns every campsite of a facility without paging,
+// for callers that need to resolve upstream campsite IDs.
+func (r *Repository) GetFacilityCampsites(ctx context.Context, facilityId int) (campsites []Campsite, err error) {
+	db := r.reader(ctx)
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select(campsiteCols()...).
+		From(`"campsite"`).
+		Where(sq.Eq{"facility_id": facilityId}).
+		OrderBy("id")
+
+	sqlStmt, sqlArgs, err := psql.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	if err := pgxscan.ScanAll(&campsites, rows); err != nil {
+		return nil, fmt.Errorf("failed to scan rows | %w", err)
+	}
+
+	return campsites, nil
+}
+
+// UpsertCampsites creates campsites by their upstream campsite ID, updating
+// the details of the ones that already exist. Equipment is left alone since
+// upstream availability doesn't report it.
+func (r *Repository) UpsertCampsites(ctx context.Context, payloads []UpsertCampsitePayload) (campsites []Campsite, err error) {
+	if len(payloads) <= 0 {
+		return []Campsite{}, nil
+	}
+
+	tx, endTxn, err := r.getTxn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer endTxn(&err)
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Insert(`"campsite"`).
+		Columns("name", "site_type", "max_occupancy", "loop", "campsite_id", "facility_id")
+
+	for _, payload := range payloads {
+		psql = psql.Values(payload.Name, payload.SiteType, payload.MaxOccupancy, payload.Loop, payload.CampsiteId, payload.FacilityId)
+	}
+
+	sqlStmt, sqlArgs, err := psql.
+		Suffix("ON CONFLICT (campsite_id) DO UPDATE SET name = EXCLUDED.name, site_type = EXCLUDED.site_type, max_occupancy = EXCLUDED.max_occupancy, loop = EXCLUDED.loop").
+		Suffix("RETURNING " + strings.Join(campsiteCols(), ", ")).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	rows, err := tx.Query(ctx, sqlStmt, sqlArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	if err := pgxscan.ScanAll(&campsites, rows); err != nil {
+		return nil, fmt.Errorf("failed to scan rows | %w", err)
+	}
+
+	return campsites, nil
+}
+
+func campsiteCols() []string {
+	return []string{
+		"id",
+		"name",
+		"site_type",
+		"max_occupancy",
+		"equipment_allowed",
+		"loop",
+		"campsite_id",
+		"facility_id",
+		"created_at",
+		"updated_at",
+	}
+}
@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/katakeda/lantrn-api-go/errs"
+)
+
+// Cursor points at the first or last row of a page. It carries the sort
+// column values of that row so the next page is found with a keyset
+// condition rather than an offset, and it is signed so clients can't craft
+// their own. Filters ties it to the filters of the listing that issued it.
+type Cursor struct {
+	Sort      string     `json:"s"`
+	Filters   string     `json:"f,omitempty"`
+	Id        int        `json:"i"`
+	Name      *string    `json:"n,omitempty"`
+	CreatedAt *time.Time `json:"c,omitempty"`
+	Prev      bool       `json:"p,omitempty"`
+}
+
+// keyset is the unique ordering a listing is paged by, ending with id so
+// rows sharing the other columns still have a stable order.
+type keyset struct {
+	sort string
+	cols []string
+	desc bool
+}
+
+func (k keyset) orderBy(prev bool) []string {
+	// Paging backwards reads the rows before the cursor in reverse and flips
+	// them back afterwards.
+	desc := k.desc != prev
+
+	orderBy := make([]string, len(k.cols))
+	for idx, col := range k.cols {
+		orderBy[idx] = col
+		if desc {
+			orderBy[idx] += " DESC"
+		}
+	}
+	return orderBy
+}
+
+func (k keyset) where(cursor *Cursor) sq.Sqlizer {
+	op := ">"
+	if k.desc != cursor.Prev {
+		op = "<"
+	}
+
+	values := make([]interface{}, len(k.cols))
+	placeholders := make([]string, len(k.cols))
+	for idx, col := range k.cols {
+		switch col {
+		case "name":
+			values[idx] = cursor.Name
+		case "created_at":
+			values[idx] = cursor.CreatedAt
+		default:
+			values[idx] = cursor.Id
+		}
+		placeholders[idx] = "?"
+	}
+
+	return sq.Expr(fmt.Sprintf("(%s) %s (%s)", strings.Join(k.cols, ", "), op, strings.Join(placeholders, ", ")), values...)
+}
+
+// cursorSigner signs and checks cursors. Every repository embeds one so
+// cursors keep working whichever implementation issued them.
+type cursorSigner struct {
+	cursorSecret []byte
+}
+
+// ensureCursorSecret falls back to a random key when none was configured.
+func (s *cursorSigner) ensureCursorSecret() error {
+	if len(s.cursorSecret) > 0 {
+		return nil
+	}
+
+	s.cursorSecret = make([]byte, 32)
+	if _, err := rand.Read(s.cursorSecret); err != nil {
+		return fmt.Errorf("failed to generate cursor secret | %w", err)
+	}
+	return nil
+}
+
+func (s *cursorSigner) encodeCursor(cursor Cursor) string {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, s.cursorSecret)
+	mac.Write(payload)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *cursorSigner) decodeCursor(encoded string, sort, filters string) (*Cursor, error) {
+	parts := strings.Split(encoded, ".")
+	if len(parts) != 2 {
+		return nil, errs.InvalidArgument("Invalid cursor", nil)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errs.InvalidArgument("Invalid cursor", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errs.InvalidArgument("Invalid cursor", err)
+	}
+
+	mac := hmac.New(sha256.New, s.cursorSecret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errs.InvalidArgument("Invalid cursor", nil)
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, errs.InvalidArgument("Invalid cursor", err)
+	}
+	if cursor.Sort != sort {
+		return nil, errs.InvalidArgument("Cursor was issued for a different sort", nil)
+	}
+	if cursor.Filters != filters {
+		return nil, errs.InvalidArgument("Cursor was issued for different filters", nil)
+	}
+
+	return &cursor, nil
+}
+
+// filterDigest identifies the filters a listing was asked for, given as key
+// and value pairs, ignoring the ones left empty. It is a hash so cursors stay
+// short and don't carry filter values such as tokens.
+func filterDigest(pairs ...string) string {
+	values := url.Values{}
+	for idx := 0; idx+1 < len(pairs); idx += 2 {
+		if pairs[idx+1] != "" {
+			values.Set(pairs[idx], pairs[idx+1])
+		}
+	}
+	if len(values) <= 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(values.Encode()))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// setCursors links a page to its neighbours. Paging forwards there is a next
+// page when more rows were fetched than fit, and a previous one whenever we
+// didn't start from the top. Paging backwards it is the other way around.
+func (s *cursorSigner) setCursors(metadata *GetMetadata, cursor *Cursor, page int, more bool, first, last Cursor) {
+	backwards := cursor != nil && cursor.Prev
+
+	if more || backwards {
+		metadata.Next = s.encodeCursor(last)
+	}
+
+	first.Prev = true
+	if (backwards && more) || (!backwards && (cursor != nil || page > 1)) {
+		metadata.Prev = s.encodeCursor(first)
+	}
+
+	if cursor != nil {
+		metadata.Page = 0
+		metadata.HasNext = metadata.Next != ""
+	}
+}
+
+// parsePage returns the requested page number, or 0 when none was given.
+func parsePage(page string) (int, error) {
+	if page == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(page)
+	if err != nil {
+		return 0, errs.InvalidArgument("Page must be a number", err)
+	}
+	if number < 1 {
+		return 0, errs.InvalidArgument("Page must be 1 or greater", nil)
+	}
+
+	return number, nil
+}
@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/katakeda/lantrn-api-go/errs"
+)
+
+func TestCursorFilters(t *testing.T) {
+	signer := &cursorSigner{}
+	if err := signer.ensureCursorSecret(); err != nil {
+		t.Fatalf("ensureCursorSecret: %v", err)
+	}
+
+	filters := GetSubscriptionsFilter{FacilityIds: "1,2", Status: SubscriptionStatusActive}.digest()
+	encoded := signer.encodeCursor(Cursor{Sort: "new", Filters: filters, Id: 7})
+
+	cursor, err := signer.decodeCursor(encoded, "new", filters)
+	if err != nil || cursor.Id != 7 {
+		t.Fatalf("decodeCursor with the same filters = %+v, %v, want id 7", cursor, err)
+	}
+
+	// Filters left empty don't count, so an unfiltered cursor carries none.
+	if digest := (GetSubscriptionsFilter{Sort: "new", PerPage: "5"}).digest(); digest != "" {
+		t.Errorf("digest of no filters = %q, want empty", digest)
+	}
+
+	for _, other := range []GetSubscriptionsFilter{
+		{},
+		{FacilityIds: "1,2"},
+		{FacilityIds: "1,2", Status: SubscriptionStatusPaused},
+		{FacilityIds: "1,2", Status: SubscriptionStatusActive, CreatedFrom: "2030-01-01"},
+	} {
+		_, err := signer.decodeCursor(encoded, "new", other.digest())
+		var e *errs.Error
+		if !errors.As(err, &e) || e.Code != errs.CodeInvalidArgument {
+			t.Errorf("decodeCursor with filters %+v = %v, want invalid_argument", other, err)
+		}
+	}
+}
@@ -2,44 +2,83 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/jackc/pgx/v4"
+	"github.com/katakeda/lantrn-api-go/errs"
 )
 
 const (
-	perPageMax    = 25
-	defaultRadius = 80000 // 80km
+	defaultPerPage = 25
+	maxPerPage     = 100
+	defaultRadius  = 80000 // 80km
 )
 
 type Facility struct {
-	Id          int      `json:"id" db:"id"`
-	Name        string   `json:"name" db:"name"`
-	Description *string  `json:"description" db:"description"`
-	Latitude    *float32 `json:"latitude" db:"latitude"`
-	Longitude   *float32 `json:"longitude" db:"longitude"`
-	FacilityId  string   `json:"facilityId" db:"facility_id"`
-	PrimaryImg  *string  `json:"primaryImg"`
+	Id          int       `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"description" db:"description"`
+	Latitude    *float32  `json:"latitude" db:"latitude"`
+	Longitude   *float32  `json:"longitude" db:"longitude"`
+	FacilityId  string    `json:"facilityId" db:"facility_id"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+	PrimaryImg  *string   `json:"primaryImg"`
 }
 
 type FacilityMedia struct {
-	Id         int     `json:"id" db:"id"`
-	Title      *string `json:"title" db:"title"`
-	Url        *string `json:"url" db:"url"`
-	IsPrimary  bool    `json:"isPrimary" db:"is_primary"`
-	FacilityId string  `json:"facilityId" db:"facility_id"`
+	Id         int       `json:"id" db:"id"`
+	Title      *string   `json:"title" db:"title"`
+	Url        *string   `json:"url" db:"url"`
+	IsPrimary  bool      `json:"isPrimary" db:"is_primary"`
+	FacilityId string    `json:"facilityId" db:"facility_id"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt  time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type FacilityDemand struct {
+	Facility            Facility `json:"facility"`
+	ActiveSubscriptions int      `json:"activeSubscriptions" db:"active_subscriptions"`
+	EarliestTargetDate  string   `json:"earliestTargetDate" db:"earliest_target_date"`
+	LatestTargetDate    string   `json:"latestTargetDate" db:"latest_target_date"`
 }
 
 type GetFacilitiesFilter struct {
-	Lat  string
-	Lng  string
-	Ids  string
-	Sort string
-	Page string
+	Lat     string
+	Lng     string
+	Ids     string
+	Sort    string
+	Page    string
+	PerPage string
+	Cursor  string
+	Fields  string
+}
+
+func (f GetFacilitiesFilter) digest() string {
+	return filterDigest("lat", f.Lat, "lng", f.Lng, "ids", f.Ids)
+}
+
+// coordinates returns the point to search near, and false when the filter
+// doesn't ask for one.
+func (f GetFacilitiesFilter) coordinates() (lat, lng float64, nearby bool, err error) {
+	if f.Lat == "" || f.Lng == "" {
+		return 0, 0, false, nil
+	}
+
+	if lat, err = strconv.ParseFloat(f.Lat, 64); err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false, errs.InvalidArgument("Latitude must be a number between -90 and 90", err)
+	}
+	if lng, err = strconv.ParseFloat(f.Lng, 64); err != nil || lng < -180 || lng > 180 {
+		return 0, 0, false, errs.InvalidArgument("Longitude must be a number between -180 and 180", err)
+	}
+
+	return lat, lng, true, nil
 }
 
 type GetFacilitiesResponse struct {
@@ -48,24 +87,18 @@ type GetFacilitiesResponse struct {
 }
 
 func (r *Repository) GetFacilities(ctx context.Context, filter GetFacilitiesFilter) (response *GetFacilitiesResponse, err error) {
-	tx, ok := ctx.Value(TxnKey).(pgx.Tx)
-	if !ok || tx == nil {
-		tx, _ = r.db.Begin(ctx)
-		defer func() error {
-			if err != nil {
-				return tx.Rollback(ctx)
-			}
-			return tx.Commit(ctx)
-		}()
+	db := r.reader(ctx)
+
+	keys := facilityKeyset(filter.Sort)
+
+	cols, selected, err := selectColumns(filter.Fields, facilityColumns, facilityComputedFields, keys.cols...)
+	if err != nil {
+		return nil, err
 	}
 
-	cols := []string{
-		"id",
-		"name",
-		"description",
-		"latitude",
-		"longitude",
-		"facility_id",
+	perPage, err := parsePerPage(filter.PerPage, r.defaultPerPage, r.maxPerPage)
+	if err != nil {
+		return nil, err
 	}
 
 	countSql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
@@ -74,20 +107,15 @@ func (r *Repository) GetFacilities(ctx context.Context, filter GetFacilitiesFilt
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
 		Select(cols...).
 		From(`"facility"`).
-		Limit(perPageMax)
+		Limit(uint64(perPage) + 1)
 
-	switch filter.Sort {
-	case "za":
-		psql = psql.OrderBy("name DESC")
-	case "new":
-		psql = psql.OrderBy("id DESC")
-	default:
-		psql = psql.OrderBy("name")
+	lat, lng, nearby, err := filter.coordinates()
+	if err != nil {
+		return nil, err
 	}
-
-	if filter.Lat != "" && filter.Lng != "" {
-		countSql = countSql.Where("ST_DWithin(geom, ST_MakePoint(?, ?)::geography, ?)", filter.Lng, filter.Lat, defaultRadius)
-		psql = psql.Where("ST_DWithin(geom, ST_MakePoint(?, ?)::geography, ?)", filter.Lng, filter.Lat, defaultRadius)
+	if nearby {
+		countSql = countSql.Where("ST_DWithin(geom, ST_MakePoint(?, ?)::geography, ?)", lng, lat, r.radius)
+		psql = psql.Where("ST_DWithin(geom, ST_MakePoint(?, ?)::geography, ?)", lng, lat, r.radius)
 	}
 
 	if filter.Ids != "" {
@@ -96,13 +124,22 @@ func (r *Repository) GetFacilities(ctx context.Context, filter GetFacilitiesFilt
 		psql = psql.Where(sq.Eq{"id": ids})
 	}
 
-	offset := 0
-	if filter.Page != "" {
-		offset, err = strconv.Atoi(filter.Page)
+	var cursor *Cursor
+	if filter.Cursor != "" {
+		cursor, err = r.decodeCursor(filter.Cursor, keys.sort, filter.digest())
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse query | %w", err)
+			return nil, err
 		}
-		psql = psql.Offset(uint64(offset-1) * perPageMax)
+		psql = psql.Where(keys.where(cursor))
+	}
+	psql = psql.OrderBy(keys.orderBy(cursor != nil && cursor.Prev)...)
+
+	page, err := parsePage(filter.Page)
+	if err != nil {
+		return nil, err
+	}
+	if page > 1 && cursor == nil {
+		psql = psql.Offset(uint64((page - 1) * perPage))
 	}
 
 	var totalCnt int
@@ -111,7 +148,7 @@ func (r *Repository) GetFacilities(ctx context.Context, filter GetFacilitiesFilt
 		if err != nil {
 			return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 		}
-		rows, err := tx.Query(ctx, sqlStmt, sqlArgs...)
+		rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 		}
@@ -120,13 +157,13 @@ func (r *Repository) GetFacilities(ctx context.Context, filter GetFacilitiesFilt
 		}
 	}
 
-	var facilities []Facility
+	facilities := []Facility{}
 	{
 		sqlStmt, sqlArgs, err := psql.ToSql()
 		if err != nil {
 			return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 		}
-		rows, err := tx.Query(ctx, sqlStmt, sqlArgs...)
+		rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 		}
@@ -135,30 +172,50 @@ func (r *Repository) GetFacilities(ctx context.Context, filter GetFacilitiesFilt
 		}
 	}
 
-	if err := r.setFacilityMedias(ctx, facilities); err != nil {
-		return nil, fmt.Errorf("failed to set facility medias | %w", err)
+	more := len(facilities) > perPage
+	if more {
+		facilities = facilities[:perPage]
+	}
+	if cursor != nil && cursor.Prev {
+		for i, j := 0, len(facilities)-1; i < j; i, j = i+1, j-1 {
+			facilities[i], facilities[j] = facilities[j], facilities[i]
+		}
+	}
+
+	if selected == nil || selected["primaryImg"] {
+		if err := r.setFacilityMedias(ctx, facilities); err != nil {
+			return nil, fmt.Errorf("failed to set facility medias | %w", err)
+		}
+	}
+
+	metadata := newMetadata(page, perPage, totalCnt)
+	if len(facilities) > 0 {
+		first, last := facilities[0], facilities[len(facilities)-1]
+		r.setCursors(&metadata, cursor, page, more,
+			Cursor{Sort: keys.sort, Filters: filter.digest(), Id: first.Id, Name: &first.Name},
+			Cursor{Sort: keys.sort, Filters: filter.digest(), Id: last.Id, Name: &last.Name},
+		)
 	}
 
 	return &GetFacilitiesResponse{
-		Data: facilities,
-		Metadata: GetMetadata{
-			Page:  offset,
-			Total: totalCnt,
-		},
+		Data:     facilities,
+		Metadata: metadata,
 	}, nil
 }
 
-func (r *Repository) GetFacility(ctx context.Context, id string) (facility *Facility, err error) {
-	tx, ok := ctx.Value(TxnKey).(pgx.Tx)
-	if !ok || tx == nil {
-		tx, _ = r.db.Begin(ctx)
-		defer func() error {
-			if err != nil {
-				return tx.Rollback(ctx)
-			}
-			return tx.Commit(ctx)
-		}()
+func facilityKeyset(sort string) keyset {
+	switch sort {
+	case "za":
+		return keyset{sort: "za", cols: []string{"name", "id"}, desc: true}
+	case "new":
+		return keyset{sort: "new", cols: []string{"id"}, desc: true}
+	default:
+		return keyset{sort: "az", cols: []string{"name", "id"}}
 	}
+}
+
+func (r *Repository) GetFacility(ctx context.Context, id string) (facility *Facility, err error) {
+	db := r.reader(ctx)
 
 	cols := []string{
 		"id",
@@ -167,6 +224,12 @@ func (r *Repository) GetFacility(ctx context.Context, id string) (facility *Faci
 		"latitude",
 		"longitude",
 		"facility_id",
+		"created_at",
+		"updated_at",
+	}
+
+	if _, err := strconv.Atoi(id); err != nil {
+		return nil, errs.InvalidArgument("Facility id must be a number", err)
 	}
 
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
@@ -180,14 +243,19 @@ func (r *Repository) GetFacility(ctx context.Context, id string) (facility *Faci
 	}
 
 	facility = &Facility{}
-	if err := tx.QueryRow(ctx, sqlStmt, sqlArgs...).Scan(
+	if err := db.QueryRow(ctx, sqlStmt, sqlArgs...).Scan(
 		&facility.Id,
 		&facility.Name,
 		&facility.Description,
 		&facility.Latitude,
 		&facility.Longitude,
 		&facility.FacilityId,
+		&facility.CreatedAt,
+		&facility.UpdatedAt,
 	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("facility %s | %w", id, ErrFacilityNotFound)
+		}
 		return nil, fmt.Errorf("failed to execute: %s args: %v | %w", sqlStmt, sqlArgs, err)
 	}
 
@@ -195,16 +263,7 @@ func (r *Repository) GetFacility(ctx context.Context, id string) (facility *Faci
 }
 
 func (r *Repository) setFacilityMedias(ctx context.Context, facilities []Facility) (err error) {
-	tx, ok := ctx.Value(TxnKey).(pgx.Tx)
-	if !ok || tx == nil {
-		tx, _ = r.db.Begin(ctx)
-		defer func() error {
-			if err != nil {
-				return tx.Rollback(ctx)
-			}
-			return tx.Commit(ctx)
-		}()
-	}
+	db := r.reader(ctx)
 
 	facilityIds := make([]string, len(facilities))
 	for idx := range facilities {
@@ -229,7 +288,7 @@ func (r *Repository) setFacilityMedias(ctx context.Context, facilities []Facilit
 		return fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 	}
 
-	rows, err := tx.Query(ctx, sqlStmt, sqlArgs...)
+	rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
 	if err != nil {
 		return fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 	}
@@ -252,3 +311,69 @@ func (r *Repository) setFacilityMedias(ctx context.Context, facilities []Facilit
 
 	return nil
 }
+
+// GetFacilityDemands returns every facility with at least one active
+// subscription for today or later, along with how many there are and the
+// range of dates they are waiting on.
+func (r *Repository) GetFacilityDemands(ctx context.Context) (demands []FacilityDemand, err error) {
+	db := r.reader(ctx)
+
+	cols := []string{
+		"f.id",
+		"f.name",
+		"f.description",
+		"f.latitude",
+		"f.longitude",
+		"f.facility_id",
+		"f.created_at",
+		"f.updated_at",
+		"COUNT(s.id) AS active_subscriptions",
+		"MIN(s.target_date) AS earliest_target_date",
+		"MAX(s.target_date) AS latest_target_date",
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select(cols...).
+		From(`"subscription" s`).
+		Join(`"facility" f ON f.id = s.facility_id`).
+		Where(sq.Or{sq.Eq{"s.status": nil}, sq.Eq{"s.status": SubscriptionStatusActive}}).
+		Where(sq.GtOrEq{"s.target_date": time.Now().UTC().Format(dateLayout)}).
+		GroupBy("f.id").
+		OrderBy("f.id")
+
+	sqlStmt, sqlArgs, err := psql.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var demand FacilityDemand
+		if err := rows.Scan(
+			&demand.Facility.Id,
+			&demand.Facility.Name,
+			&demand.Facility.Description,
+			&demand.Facility.Latitude,
+			&demand.Facility.Longitude,
+			&demand.Facility.FacilityId,
+			&demand.Facility.CreatedAt,
+			&demand.Facility.UpdatedAt,
+			&demand.ActiveSubscriptions,
+			&demand.EarliestTargetDate,
+			&demand.LatestTargetDate,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan rows | %w", err)
+		}
+		demands = append(demands, demand)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows | %w", err)
+	}
+
+	return demands, nil
+}
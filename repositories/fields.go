@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/errs"
+)
+
+// column maps a JSON field clients can ask for to the column it's read from.
+type column struct {
+	field string
+	col   string
+}
+
+var facilityColumns = []column{
+	{"id", "id"},
+	{"name", "name"},
+	{"description", "description"},
+	{"latitude", "latitude"},
+	{"longitude", "longitude"},
+	{"facilityId", "facility_id"},
+	{"createdAt", "created_at"},
+	{"updatedAt", "updated_at"},
+}
+
+// facilityComputedFields are filled in after the query and need the listed
+// columns to do so.
+var facilityComputedFields = map[string][]string{
+	"primaryImg": {"facility_id"},
+}
+
+var subscriptionColumns = []column{
+	{"id", "id"},
+	{"email", "email"},
+	{"targetDate", "target_date"},
+	{"facilityId", "facility_id"},
+	{"campsiteIds", "campsite_ids"},
+	{"siteType", "site_type"},
+	{"status", "status"},
+	{"createdAt", "created_at"},
+	{"updatedAt", "updated_at"},
+}
+
+func columnNames(columns []column) []string {
+	names := make([]string, len(columns))
+	for idx, c := range columns {
+		names[idx] = c.col
+	}
+	return names
+}
+
+// selectColumns turns a comma separated list of fields into the columns to
+// select, always including required ones such as the sort keys. selected is
+// nil when every field was asked for.
+func selectColumns(fields string, columns []column, computed map[string][]string, required ...string) (cols []string, selected map[string]bool, err error) {
+	seen := make(map[string]bool)
+	add := func(col string) {
+		if !seen[col] {
+			seen[col] = true
+			cols = append(cols, col)
+		}
+	}
+
+	if fields == "" {
+		for _, c := range columns {
+			add(c.col)
+		}
+		return cols, nil, nil
+	}
+
+	selected = make(map[string]bool)
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		found := false
+		for _, c := range columns {
+			if c.field == field {
+				add(c.col)
+				found = true
+				break
+			}
+		}
+		if deps, ok := computed[field]; ok {
+			for _, col := range deps {
+				add(col)
+			}
+			found = true
+		}
+		if !found {
+			return nil, nil, errs.InvalidArgument(fmt.Sprintf("Unknown field %q", field), nil)
+		}
+		selected[field] = true
+	}
+
+	for _, col := range required {
+		add(col)
+	}
+
+	return cols, selected, nil
+}
+
+// parseTime accepts either a date, meaning midnight UTC, or an RFC 3339
+// timestamp.
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parsePerPage returns the requested page size, or def when none was given,
+// bounded by max.
+func parsePerPage(perPage string, def, max int) (int, error) {
+	if perPage == "" {
+		if def < max {
+			return def, nil
+		}
+		return max, nil
+	}
+
+	number, err := strconv.Atoi(perPage)
+	if err != nil {
+		return 0, errs.InvalidArgument("Per page must be a number", err)
+	}
+	if number < 1 || number > max {
+		return 0, errs.InvalidArgument(fmt.Sprintf("Per page must be between 1 and %d", max), nil)
+	}
+
+	return number, nil
+}
@@ -2,10 +2,14 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/katakeda/lantrn-api-go/errs"
 )
 
 type CtxKey string
@@ -14,37 +18,154 @@ const (
 	TxnKey CtxKey = "txnKey"
 )
 
+var (
+	ErrFacilityNotFound     = errs.NotFound("Facility not found", nil)
+	ErrSubscriptionNotFound = errs.NotFound("Subscription not found", nil)
+)
+
 type GetMetadata struct {
-	Page  int `json:"page"`
-	Total int `json:"total"`
+	Page       int    `json:"page"`
+	PerPage    int    `json:"perPage"`
+	Total      int    `json:"total"`
+	TotalPages int    `json:"totalPages"`
+	HasNext    bool   `json:"hasNext"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+// newMetadata describes a page of a listing. The page is kept as asked for,
+// 0 when the caller didn't ask, since version 1 reports it that way; a page
+// below 1 is the first one when working out whether there is a next.
+func newMetadata(page, perPage, total int) GetMetadata {
+	totalPages := 0
+	if perPage > 0 {
+		totalPages = (total + perPage - 1) / perPage
+	}
+
+	current := page
+	if current < 1 {
+		current = 1
+	}
+
+	return GetMetadata{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+		HasNext:    current < totalPages,
+	}
 }
 
 type IRepository interface {
 	BeginTxn(ctx context.Context) (context.Context, error)
 	CommitTxn(ctx context.Context) error
 	RollbackTxn(ctx context.Context) error
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
 
 	GetFacilities(ctx context.Context, filter GetFacilitiesFilter) (*GetFacilitiesResponse, error)
 	GetFacility(ctx context.Context, id string) (*Facility, error)
+	GetFacilityDemands(ctx context.Context) ([]FacilityDemand, error)
+	GetCampsites(ctx context.Context, filter GetCampsitesFilter) (*GetCampsitesResponse, error)
+	GetFacilityCampsites(ctx context.Context, facilityId int) ([]Campsite, error)
+	UpsertCampsites(ctx context.Context, payloads []UpsertCampsitePayload) ([]Campsite, error)
+	GetAvailability(ctx context.Context, filter GetAvailabilityFilter) (*GetAvailabilityResponse, error)
+	GetAvailabilityHistory(ctx context.Context, filter GetAvailabilityFilter) (*GetAvailabilityHistoryResponse, error)
+	GetLatestAvailabilitySnapshots(ctx context.Context, filter GetAvailabilityFilter) ([]AvailabilitySnapshot, error)
+	CreateAvailabilitySnapshots(ctx context.Context, payloads []CreateAvailabilitySnapshotPayload) error
 	GetSubscriptions(ctx context.Context, filter GetSubscriptionsFilter) (*GetSubscriptionsResponse, error)
+	GetSubscription(ctx context.Context, id string) (*Subscription, error)
+	GetActiveSubscriptions(ctx context.Context, filter GetActiveSubscriptionsFilter) ([]Subscription, error)
 	CreateSubscription(ctx context.Context, payload CreateSubscriptionPayload) (*Subscription, error)
 	UpdateSubscription(ctx context.Context, id string, payload UpdateSubscriptionPayload) (*Subscription, error)
 	GetSubscriptionTokens(ctx context.Context, filter GetSubscriptionTokensFilter) (*GetSubscriptionTokensResponse, error)
 	CreateSubscriptionToken(ctx context.Context, payload CreateSubscriptionTokenPayload) (*SubscriptionToken, error)
+	GetPendingNotifications(ctx context.Context, filter GetPendingNotificationsFilter) ([]Notification, error)
+	CreateNotifications(ctx context.Context, payloads []CreateNotificationPayload) error
+	MarkNotificationsDelivered(ctx context.Context, ids []int) error
 }
 
 type Repository struct {
-	db *pgxpool.Pool
+	cursorSigner
+	db     *pgxpool.Pool
+	readDB *pgxpool.Pool
+
+	defaultPerPage int
+	maxPerPage     int
+	radius         float64
+}
+
+// querier runs queries on a pool or inside a transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+type Option func(*Repository)
+
+// WithCursorSecret sets the key pagination cursors are signed with. Without
+// it a random key is used, so cursors stop working after a restart.
+func WithCursorSecret(secret []byte) Option {
+	return func(r *Repository) {
+		r.cursorSecret = secret
+	}
+}
+
+// WithReadDB routes reads made outside a transaction to db, usually a read
+// replica. Reads inside a transaction stay on it so they see its writes.
+func WithReadDB(db *pgxpool.Pool) Option {
+	return func(r *Repository) {
+		r.readDB = db
+	}
+}
+
+// WithDefaultPerPage sets the page size used when clients don't ask for one.
+func WithDefaultPerPage(perPage int) Option {
+	return func(r *Repository) {
+		r.defaultPerPage = perPage
+	}
+}
+
+// WithMaxPerPage bounds the page size clients can ask for.
+func WithMaxPerPage(max int) Option {
+	return func(r *Repository) {
+		r.maxPerPage = max
+	}
 }
 
-func NewRepository(db *pgxpool.Pool) (*Repository, error) {
+// WithRadius sets how far from a point, in meters, facilities are searched
+// for.
+func WithRadius(meters float64) Option {
+	return func(r *Repository) {
+		r.radius = meters
+	}
+}
+
+func NewRepository(db *pgxpool.Pool, opts ...Option) (*Repository, error) {
 	if err := db.Ping(context.Background()); err != nil {
 		return nil, err
 	}
 
-	return &Repository{
-		db: db,
-	}, nil
+	r := &Repository{
+		db:             db,
+		defaultPerPage: defaultPerPage,
+		maxPerPage:     maxPerPage,
+		radius:         defaultRadius,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.readDB != nil {
+		if err := r.readDB.Ping(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to reach read DB | %w", err)
+		}
+	}
+
+	if err := r.ensureCursorSecret(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
 }
 
 func (r Repository) BeginTxn(ctx context.Context) (context.Context, error) {
@@ -73,3 +194,126 @@ func (r Repository) RollbackTxn(ctx context.Context) error {
 
 	return tx.(pgx.Tx).Rollback(ctx)
 }
+
+// WithTx runs fn in a transaction, committing it when fn succeeds and rolling
+// it back when fn fails or panics. fn joins the transaction already in ctx
+// when there is one, leaving it to whoever began it.
+func (r Repository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withTx(ctx, r, fn)
+}
+
+type txnBeginner interface {
+	BeginTxn(ctx context.Context) (context.Context, error)
+	CommitTxn(ctx context.Context) error
+	RollbackTxn(ctx context.Context) error
+}
+
+func withTx(ctx context.Context, r txnBeginner, fn func(ctx context.Context) error) (err error) {
+	if ctx.Value(TxnKey) != nil {
+		return fn(ctx)
+	}
+
+	txCtx, err := r.BeginTxn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin txn | %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			r.RollbackTxn(txCtx)
+			panic(p)
+		}
+		if err != nil {
+			if rollbackErr := r.RollbackTxn(txCtx); rollbackErr != nil {
+				slog.ErrorContext(ctx, "Failed to rollback txn", "error", rollbackErr)
+			}
+		}
+	}()
+
+	if err := fn(txCtx); err != nil {
+		return err
+	}
+
+	if err := r.CommitTxn(txCtx); err != nil {
+		return fmt.Errorf("failed to commit txn | %w", err)
+	}
+
+	return nil
+}
+
+// reader returns what a read should run on: the transaction in ctx, or
+// else the read pool without a transaction of its own.
+func (r *Repository) reader(ctx context.Context) querier {
+	if tx, ok := ctx.Value(TxnKey).(pgx.Tx); ok && tx != nil {
+		return tx
+	}
+	if r.readDB != nil {
+		return r.readDB
+	}
+	return r.db
+}
+
+// getTxn returns the transaction in ctx. Without one it begins a transaction
+// of its own, which endTxn commits, or rolls back when *err is set.
+func (r *Repository) getTxn(ctx context.Context) (tx pgx.Tx, endTxn func(err *error), err error) {
+	if tx, ok := ctx.Value(TxnKey).(pgx.Tx); ok && tx != nil {
+		return tx, func(*error) {}, nil
+	}
+
+	tx, err = r.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin txn | %w", err)
+	}
+
+	return tx, func(err *error) {
+		if p := recover(); p != nil {
+			tx.Rollback(ctx)
+			panic(p)
+		}
+		if *err != nil {
+			tx.Rollback(ctx)
+			return
+		}
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			*err = fmt.Errorf("failed to commit txn | %w", commitErr)
+		}
+	}, nil
+}
+
+// isForeignKeyViolation reports whether err was caused by constraint pointing
+// at a row that doesn't exist.
+func isForeignKeyViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503" && pgErr.ConstraintName == constraint
+}
+
+// Ping reaches the primary and, when there is one, the read replica.
+func (r Repository) Ping(ctx context.Context) error {
+	if err := r.db.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to reach DB | %w", err)
+	}
+	if r.readDB != nil {
+		if err := r.readDB.Ping(ctx); err != nil {
+			return fmt.Errorf("failed to reach read DB | %w", err)
+		}
+	}
+
+	return nil
+}
+
+// MigrationVersion returns the newest migration goose applied to the primary.
+// Goose records a rollback as a new row, so only the latest row of each
+// version says whether it is still applied.
+func (r Repository) MigrationVersion(ctx context.Context) (int64, error) {
+	sqlStmt := `SELECT COALESCE(MAX(version_id), 0) FROM (
+		SELECT DISTINCT ON (version_id) version_id, is_applied
+		FROM goose_db_version
+		ORDER BY version_id, id DESC
+	) AS latest WHERE is_applied`
+
+	var version int64
+	if err := r.db.QueryRow(ctx, sqlStmt).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to execute: %s | %w", sqlStmt, err)
+	}
+
+	return version, nil
+}
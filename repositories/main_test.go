@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewMetadata(t *testing.T) {
+	tests := []struct {
+		name                 string
+		page, perPage, total int
+		want                 GetMetadata
+	}{
+		{
+			name: "no page asked for",
+			page: 0, perPage: 10, total: 25,
+			want: GetMetadata{Page: 0, PerPage: 10, Total: 25, TotalPages: 3, HasNext: true},
+		},
+		{
+			name: "middle page",
+			page: 2, perPage: 10, total: 25,
+			want: GetMetadata{Page: 2, PerPage: 10, Total: 25, TotalPages: 3, HasNext: true},
+		},
+		{
+			name: "last page",
+			page: 3, perPage: 10, total: 25,
+			want: GetMetadata{Page: 3, PerPage: 10, Total: 25, TotalPages: 3},
+		},
+		{
+			name: "past the last page",
+			page: 5, perPage: 10, total: 25,
+			want: GetMetadata{Page: 5, PerPage: 10, Total: 25, TotalPages: 3},
+		},
+		{
+			name: "empty listing",
+			page: 0, perPage: 10, total: 0,
+			want: GetMetadata{PerPage: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newMetadata(tt.page, tt.perPage, tt.total); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newMetadata(%d, %d, %d) = %+v, want %+v", tt.page, tt.perPage, tt.total, got, tt.want)
+			}
+		})
+	}
+}
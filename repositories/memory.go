@@ -0,0 +1,1182 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/errs"
+)
+
+// MemoryRepository keeps everything in memory. It behaves like Repository,
+// including transactions, filtering and pagination, so handlers can be run
+// without a database in tests and local demos.
+type MemoryRepository struct {
+	cursorSigner
+	defaultPerPage int
+	maxPerPage     int
+	radius         float64
+
+	mu    sync.Mutex
+	state *memoryState
+
+	// txnLock is held from BeginTxn until the transaction ends, and by writes
+	// made outside of one, so a commit never replaces changes it didn't see.
+	// Transactions run one at a time rather than isolated from each other.
+	// It is a channel rather than a mutex so waiting for it can be cancelled.
+	txnLock chan struct{}
+}
+
+// memoryState holds every table. Writes are made to a copy which replaces
+// the original once they succeed, so a failed write leaves nothing behind.
+type memoryState struct {
+	facilities         []Facility
+	facilityMedias     []FacilityMedia
+	campsites          []Campsite
+	snapshots          []AvailabilitySnapshot
+	subscriptions      []Subscription
+	subscriptionTokens []SubscriptionToken
+	notifications      []Notification
+	sequences          map[string]int
+}
+
+// memoryTxn works on its own copy of the state, which replaces the
+// repository's on commit. Nothing else writes in the meantime since the
+// transaction holds txnLock.
+type memoryTxn struct {
+	state  *memoryState
+	closed bool
+	done   chan struct{}
+}
+
+// NewMemoryRepository returns an empty repository. It accepts the same
+// options as NewRepository.
+func NewMemoryRepository(opts ...Option) (*MemoryRepository, error) {
+	config := &Repository{
+		defaultPerPage: defaultPerPage,
+		maxPerPage:     maxPerPage,
+		radius:         defaultRadius,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	r := &MemoryRepository{
+		cursorSigner:   config.cursorSigner,
+		defaultPerPage: config.defaultPerPage,
+		maxPerPage:     config.maxPerPage,
+		radius:         config.radius,
+		state:          &memoryState{sequences: make(map[string]int)},
+		txnLock:        make(chan struct{}, 1),
+	}
+	if err := r.ensureCursorSecret(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *MemoryRepository) BeginTxn(ctx context.Context) (context.Context, error) {
+	if err := r.lockTxn(ctx); err != nil {
+		return ctx, fmt.Errorf("failed to begin txn | %w", err)
+	}
+
+	r.mu.Lock()
+	txn := &memoryTxn{state: r.state.clone(), done: make(chan struct{})}
+	r.mu.Unlock()
+
+	// A transaction its caller abandoned is rolled back once its context is
+	// done, as Postgres does when the connection goes away, rather than
+	// holding up every later writer. Done is read here rather than in the
+	// goroutine since a *gin.Context is reused once its request is handled.
+	done := ctx.Done()
+	go func() {
+		select {
+		case <-done:
+			r.endTxn(txn, false)
+		case <-txn.done:
+		}
+	}()
+
+	return context.WithValue(ctx, TxnKey, txn), nil
+}
+
+func (r *MemoryRepository) CommitTxn(ctx context.Context) error {
+	txn, ok := ctx.Value(TxnKey).(*memoryTxn)
+	if !ok || txn == nil {
+		return fmt.Errorf("failed to get txn from ctx")
+	}
+
+	return r.endTxn(txn, true)
+}
+
+func (r *MemoryRepository) RollbackTxn(ctx context.Context) error {
+	txn, ok := ctx.Value(TxnKey).(*memoryTxn)
+	if !ok || txn == nil {
+		return fmt.Errorf("failed to get txn from ctx")
+	}
+
+	return r.endTxn(txn, false)
+}
+
+// endTxn closes txn, keeping what it wrote when commit is set, and lets the
+// next transaction begin.
+func (r *MemoryRepository) endTxn(txn *memoryTxn, commit bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if txn.closed {
+		return fmt.Errorf("txn is already closed")
+	}
+	txn.closed = true
+	close(txn.done)
+	if commit {
+		r.state = txn.state
+	}
+	r.unlockTxn()
+
+	return nil
+}
+
+// lockTxn waits for txnLock, giving up once ctx is done.
+func (r *MemoryRepository) lockTxn(ctx context.Context) error {
+	select {
+	case r.txnLock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *MemoryRepository) unlockTxn() {
+	<-r.txnLock
+}
+
+func (r *MemoryRepository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withTx(ctx, r, fn)
+}
+
+// AddFacility stores a facility, assigning it an id when it has none. There is
+// no way to create facilities through IRepository, so tests and demos seed
+// them with this.
+func (r *MemoryRepository) AddFacility(ctx context.Context, facility Facility) (*Facility, error) {
+	err := r.write(ctx, func(state *memoryState) error {
+		if facility.Id == 0 {
+			facility.Id = state.nextId("facility")
+		} else if facility.Id > state.sequences["facility"] {
+			state.sequences["facility"] = facility.Id
+		}
+		now := time.Now()
+		facility.CreatedAt, facility.UpdatedAt = now, now
+		facility.PrimaryImg = nil
+		state.facilities = append(state.facilities, facility)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &facility, nil
+}
+
+// AddFacilityMedia stores a media item of a facility.
+func (r *MemoryRepository) AddFacilityMedia(ctx context.Context, media FacilityMedia) (*FacilityMedia, error) {
+	err := r.write(ctx, func(state *memoryState) error {
+		media.Id = state.nextId("facility_media")
+		now := time.Now()
+		media.CreatedAt, media.UpdatedAt = now, now
+		state.facilityMedias = append(state.facilityMedias, media)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &media, nil
+}
+
+func (r *MemoryRepository) GetFacilities(ctx context.Context, filter GetFacilitiesFilter) (response *GetFacilitiesResponse, err error) {
+	keys := facilityKeyset(filter.Sort)
+
+	_, selected, err := selectColumns(filter.Fields, facilityColumns, facilityComputedFields, keys.cols...)
+	if err != nil {
+		return nil, err
+	}
+
+	perPage, err := parsePerPage(filter.PerPage, r.defaultPerPage, r.maxPerPage)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.read(ctx, func(state *memoryState) error {
+		var ids []string
+		if filter.Ids != "" {
+			ids = strings.Split(filter.Ids, ",")
+		}
+
+		lat, lng, nearby, err := filter.coordinates()
+		if err != nil {
+			return err
+		}
+
+		matches := []Facility{}
+		for _, facility := range state.facilities {
+			if ids != nil && !containsString(ids, strconv.Itoa(facility.Id)) {
+				continue
+			}
+			if nearby && (facility.Latitude == nil || facility.Longitude == nil ||
+				distance(lat, lng, float64(*facility.Latitude), float64(*facility.Longitude)) > r.radius) {
+				continue
+			}
+			matches = append(matches, facility)
+		}
+
+		rows := make([]Cursor, len(matches))
+		for idx := range matches {
+			rows[idx] = Cursor{Id: matches[idx].Id, Name: &matches[idx].Name}
+		}
+
+		indices, metadata, err := r.paginate(keys, filter.digest(), rows, filter.Cursor, filter.Page, perPage)
+		if err != nil {
+			return err
+		}
+
+		facilities := make([]Facility, len(indices))
+		for idx, match := range indices {
+			facilities[idx] = matches[match]
+		}
+
+		if selected == nil || selected["primaryImg"] {
+			state.setFacilityMedias(facilities)
+		}
+
+		response = &GetFacilitiesResponse{
+			Data:     facilities,
+			Metadata: metadata,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (r *MemoryRepository) GetFacility(ctx context.Context, id string) (facility *Facility, err error) {
+	facilityId, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, errs.InvalidArgument("Facility id must be a number", err)
+	}
+
+	err = r.read(ctx, func(state *memoryState) error {
+		found := state.facility(facilityId)
+		if found == nil {
+			return fmt.Errorf("facility %s | %w", id, ErrFacilityNotFound)
+		}
+		facility = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return facility, nil
+}
+
+func (r *MemoryRepository) GetFacilityDemands(ctx context.Context) (demands []FacilityDemand, err error) {
+	today := time.Now().UTC().Format(dateLayout)
+
+	err = r.read(ctx, func(state *memoryState) error {
+		demandsMap := make(map[int]*FacilityDemand)
+		for _, subscription := range state.subscriptions {
+			if !isActiveSubscription(subscription) || subscription.TargetDate < today {
+				continue
+			}
+
+			demand, ok := demandsMap[subscription.FacilityId]
+			if !ok {
+				facility := state.facility(subscription.FacilityId)
+				if facility == nil {
+					continue
+				}
+				facility.PrimaryImg = nil
+				demand = &FacilityDemand{
+					Facility:           *facility,
+					EarliestTargetDate: subscription.TargetDate,
+					LatestTargetDate:   subscription.TargetDate,
+				}
+				demandsMap[subscription.FacilityId] = demand
+			}
+
+			demand.ActiveSubscriptions++
+			if subscription.TargetDate < demand.EarliestTargetDate {
+				demand.EarliestTargetDate = subscription.TargetDate
+			}
+			if subscription.TargetDate > demand.LatestTargetDate {
+				demand.LatestTargetDate = subscription.TargetDate
+			}
+		}
+
+		for _, demand := range demandsMap {
+			demands = append(demands, *demand)
+		}
+		sort.Slice(demands, func(i, j int) bool {
+			return demands[i].Facility.Id < demands[j].Facility.Id
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return demands, nil
+}
+
+func (r *MemoryRepository) GetCampsites(ctx context.Context, filter GetCampsitesFilter) (response *GetCampsitesResponse, err error) {
+	page, err := parsePage(filter.Page)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.read(ctx, func(state *memoryState) error {
+		campsites := []Campsite{}
+		for _, campsite := range state.campsites {
+			if filter.FacilityId != "" && strconv.Itoa(campsite.FacilityId) != filter.FacilityId {
+				continue
+			}
+			if filter.SiteType != "" && (campsite.SiteType == nil || *campsite.SiteType != filter.SiteType) {
+				continue
+			}
+			if filter.Loop != "" && (campsite.Loop == nil || *campsite.Loop != filter.Loop) {
+				continue
+			}
+			campsites = append(campsites, campsite)
+		}
+
+		// Postgres sorts null loops last.
+		sort.SliceStable(campsites, func(i, j int) bool {
+			a, b := campsites[i], campsites[j]
+			if (a.Loop == nil) != (b.Loop == nil) {
+				return b.Loop == nil
+			}
+			if a.Loop != nil && *a.Loop != *b.Loop {
+				return *a.Loop < *b.Loop
+			}
+			return a.Name < b.Name
+		})
+
+		start, end := pageBounds(len(campsites), page, r.defaultPerPage)
+
+		response = &GetCampsitesResponse{
+			Data:     campsites[start:end],
+			Metadata: newMetadata(page, r.defaultPerPage, len(campsites)),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (r *MemoryRepository) GetFacilityCampsites(ctx context.Context, facilityId int) (campsites []Campsite, err error) {
+	err = r.read(ctx, func(state *memoryState) error {
+		for _, campsite := range state.campsites {
+			if campsite.FacilityId == facilityId {
+				campsites = append(campsites, campsite)
+			}
+		}
+		sort.Slice(campsites, func(i, j int) bool {
+			return campsites[i].Id < campsites[j].Id
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return campsites, nil
+}
+
+func (r *MemoryRepository) UpsertCampsites(ctx context.Context, payloads []UpsertCampsitePayload) (campsites []Campsite, err error) {
+	if len(payloads) <= 0 {
+		return []Campsite{}, nil
+	}
+
+	now := time.Now()
+	err = r.write(ctx, func(state *memoryState) error {
+		for _, payload := range payloads {
+			if state.facility(payload.FacilityId) == nil {
+				return fmt.Errorf("campsite %s references missing facility %d", payload.CampsiteId, payload.FacilityId)
+			}
+
+			idx := -1
+			for i := range state.campsites {
+				if state.campsites[i].CampsiteId == payload.CampsiteId {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				state.campsites = append(state.campsites, Campsite{
+					Id:               state.nextId("campsite"),
+					EquipmentAllowed: []string{},
+					CampsiteId:       payload.CampsiteId,
+					FacilityId:       payload.FacilityId,
+					CreatedAt:        now,
+				})
+				idx = len(state.campsites) - 1
+			}
+
+			campsite := &state.campsites[idx]
+			campsite.Name = payload.Name
+			campsite.SiteType = payload.SiteType
+			campsite.MaxOccupancy = payload.MaxOccupancy
+			campsite.Loop = payload.Loop
+			campsite.UpdatedAt = now
+			campsites = append(campsites, *campsite)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return campsites, nil
+}
+
+func (r *MemoryRepository) GetAvailability(ctx context.Context, filter GetAvailabilityFilter) (response *GetAvailabilityResponse, err error) {
+	snapshots, err := r.GetLatestAvailabilitySnapshots(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest availability snapshots | %w", err)
+	}
+
+	calendar := buildAvailabilityCalendar(snapshots)
+
+	return &GetAvailabilityResponse{
+		Data: calendar,
+		// Calendars and histories are never split, they are the first page.
+		Metadata: newMetadata(1, len(calendar), len(calendar)),
+	}, nil
+}
+
+func (r *MemoryRepository) GetLatestAvailabilitySnapshots(ctx context.Context, filter GetAvailabilityFilter) (snapshots []AvailabilitySnapshot, err error) {
+	history, err := r.availabilitySnapshots(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// History is ordered by observation within each campsite and date, so
+	// the last one of each run is the current state.
+	for idx := range history {
+		next := idx + 1
+		if next < len(history) && sameCampsite(history[idx].CampsiteId, history[next].CampsiteId) && history[idx].Date == history[next].Date {
+			continue
+		}
+		snapshots = append(snapshots, history[idx])
+	}
+
+	return snapshots, nil
+}
+
+func (r *MemoryRepository) GetAvailabilityHistory(ctx context.Context, filter GetAvailabilityFilter) (response *GetAvailabilityHistoryResponse, err error) {
+	snapshots, err := r.availabilitySnapshots(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	openings := findAvailabilityOpenings(snapshots)
+
+	return &GetAvailabilityHistoryResponse{
+		Data:    openings,
+		Summary: summarizeAvailabilityOpenings(openings),
+		// Calendars and histories are never split, they are the first page.
+		Metadata: newMetadata(1, len(openings), len(openings)),
+	}, nil
+}
+
+func (r *MemoryRepository) CreateAvailabilitySnapshots(ctx context.Context, payloads []CreateAvailabilitySnapshotPayload) error {
+	if len(payloads) <= 0 {
+		return nil
+	}
+
+	return r.write(ctx, func(state *memoryState) error {
+		for _, payload := range payloads {
+			observedAt := payload.ObservedAt
+			if observedAt.IsZero() {
+				observedAt = time.Now()
+			}
+			state.snapshots = append(state.snapshots, AvailabilitySnapshot{
+				Id:         state.nextId("availability_snapshot"),
+				FacilityId: payload.FacilityId,
+				CampsiteId: payload.CampsiteId,
+				Date:       payload.Date,
+				Status:     payload.Status,
+				ObservedAt: observedAt,
+			})
+		}
+		return nil
+	})
+}
+
+// availabilitySnapshots returns the snapshots in range ordered by campsite,
+// date and observation time.
+func (r *MemoryRepository) availabilitySnapshots(ctx context.Context, filter GetAvailabilityFilter) (snapshots []AvailabilitySnapshot, err error) {
+	from, to, err := parseDateRange(filter.From, filter.To)
+	if err != nil {
+		return nil, errs.InvalidArgument("from and to must be a valid date range", err)
+	}
+
+	err = r.read(ctx, func(state *memoryState) error {
+		for _, snapshot := range state.snapshots {
+			if strconv.Itoa(snapshot.FacilityId) != filter.FacilityId || snapshot.Date < from || snapshot.Date > to {
+				continue
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		a, b := snapshots[i], snapshots[j]
+		if !sameCampsite(a.CampsiteId, b.CampsiteId) {
+			if a.CampsiteId == nil || b.CampsiteId == nil {
+				return b.CampsiteId == nil
+			}
+			return *a.CampsiteId < *b.CampsiteId
+		}
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		return a.ObservedAt.Before(b.ObservedAt)
+	})
+
+	return snapshots, nil
+}
+
+func (r *MemoryRepository) GetSubscriptions(ctx context.Context, filter GetSubscriptionsFilter) (response *GetSubscriptionsResponse, err error) {
+	keys := subscriptionKeyset(filter.Sort)
+
+	if _, _, err := selectColumns(filter.Fields, subscriptionColumns, nil, keys.cols...); err != nil {
+		return nil, err
+	}
+
+	perPage, err := parsePerPage(filter.PerPage, r.defaultPerPage, r.maxPerPage)
+	if err != nil {
+		return nil, err
+	}
+
+	var createdFrom, createdTo time.Time
+	if filter.CreatedFrom != "" {
+		if createdFrom, err = parseTime(filter.CreatedFrom); err != nil {
+			return nil, errs.InvalidArgument("Created from must be a date or timestamp", err)
+		}
+	}
+	if filter.CreatedTo != "" {
+		if createdTo, err = parseTime(filter.CreatedTo); err != nil {
+			return nil, errs.InvalidArgument("Created to must be a date or timestamp", err)
+		}
+	}
+
+	var facilityIds []string
+	if filter.FacilityIds != "" {
+		facilityIds = strings.Split(filter.FacilityIds, ",")
+	}
+
+	err = r.read(ctx, func(state *memoryState) error {
+		matches := []Subscription{}
+		for _, subscription := range state.subscriptions {
+			if filter.Status != "" && (subscription.Status == nil || *subscription.Status != filter.Status) {
+				continue
+			}
+			if facilityIds != nil && !containsString(facilityIds, strconv.Itoa(subscription.FacilityId)) {
+				continue
+			}
+			if !createdFrom.IsZero() && subscription.CreatedAt.Before(createdFrom) {
+				continue
+			}
+			if !createdTo.IsZero() && !subscription.CreatedAt.Before(createdTo) {
+				continue
+			}
+			matches = append(matches, subscription)
+		}
+
+		rows := make([]Cursor, len(matches))
+		for idx := range matches {
+			rows[idx] = Cursor{Id: matches[idx].Id, CreatedAt: &matches[idx].CreatedAt}
+		}
+
+		indices, metadata, err := r.paginate(keys, filter.digest(), rows, filter.Cursor, filter.Page, perPage)
+		if err != nil {
+			return err
+		}
+
+		subscriptions := make([]Subscription, len(indices))
+		for idx, match := range indices {
+			subscriptions[idx] = matches[match]
+		}
+
+		response = &GetSubscriptionsResponse{
+			Data:     subscriptions,
+			Metadata: metadata,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (r *MemoryRepository) GetSubscription(ctx context.Context, id string) (subscription *Subscription, err error) {
+	subscriptionId, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, errs.InvalidArgument("Subscription id must be a number", err)
+	}
+
+	err = r.read(ctx, func(state *memoryState) error {
+		idx := state.subscriptionIndex(subscriptionId)
+		if idx < 0 {
+			return fmt.Errorf("subscription %s | %w", id, ErrSubscriptionNotFound)
+		}
+		found := state.subscriptions[idx]
+		subscription = &found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (r *MemoryRepository) GetActiveSubscriptions(ctx context.Context, filter GetActiveSubscriptionsFilter) (subscriptions []Subscription, err error) {
+	err = r.read(ctx, func(state *memoryState) error {
+		for _, subscription := range state.subscriptions {
+			if !isActiveSubscription(subscription) {
+				continue
+			}
+			if filter.FacilityId != 0 && subscription.FacilityId != filter.FacilityId {
+				continue
+			}
+			if len(filter.TargetDates) > 0 && !containsString(filter.TargetDates, subscription.TargetDate) {
+				continue
+			}
+			subscriptions = append(subscriptions, subscription)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+func (r *MemoryRepository) CreateSubscription(ctx context.Context, payload CreateSubscriptionPayload) (subscription *Subscription, err error) {
+	err = r.write(ctx, func(state *memoryState) error {
+		if state.facility(payload.FacilityId) == nil {
+			return fmt.Errorf("facility %d | %w", payload.FacilityId, ErrFacilityNotFound)
+		}
+
+		status := payload.Status
+		if status == nil {
+			active := SubscriptionStatusActive
+			status = &active
+		}
+
+		now := time.Now()
+		subscription = &Subscription{
+			Id:          state.nextId("subscription"),
+			Email:       payload.Email,
+			TargetDate:  payload.TargetDate,
+			FacilityId:  payload.FacilityId,
+			CampsiteIds: payload.CampsiteIds,
+			SiteType:    payload.SiteType,
+			Status:      status,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		state.subscriptions = append(state.subscriptions, *subscription)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (r *MemoryRepository) UpdateSubscription(ctx context.Context, id string, payload UpdateSubscriptionPayload) (subscription *Subscription, err error) {
+	subscriptionId, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, errs.InvalidArgument("Subscription id must be a number", err)
+	}
+
+	err = r.write(ctx, func(state *memoryState) error {
+		idx := state.subscriptionIndex(subscriptionId)
+		if idx < 0 {
+			return fmt.Errorf("subscription %s | %w", id, ErrSubscriptionNotFound)
+		}
+
+		updated := &state.subscriptions[idx]
+		if payload.Status != nil {
+			status := *payload.Status
+			updated.Status = &status
+		}
+		updated.UpdatedAt = time.Now()
+
+		found := *updated
+		subscription = &found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (r *MemoryRepository) GetSubscriptionTokens(ctx context.Context, filter GetSubscriptionTokensFilter) (response *GetSubscriptionTokensResponse, err error) {
+	keys := keyset{sort: "id", cols: []string{"id"}}
+
+	perPage, err := parsePerPage(filter.PerPage, r.defaultPerPage, r.maxPerPage)
+	if err != nil {
+		return nil, err
+	}
+
+	var createdFrom, createdTo time.Time
+	if filter.CreatedFrom != "" {
+		if createdFrom, err = parseTime(filter.CreatedFrom); err != nil {
+			return nil, errs.InvalidArgument("Created from must be a date or timestamp", err)
+		}
+	}
+	if filter.CreatedTo != "" {
+		if createdTo, err = parseTime(filter.CreatedTo); err != nil {
+			return nil, errs.InvalidArgument("Created to must be a date or timestamp", err)
+		}
+	}
+
+	var subscriptionIds []string
+	if filter.SubscriptionIds != "" {
+		subscriptionIds = strings.Split(filter.SubscriptionIds, ",")
+	}
+
+	err = r.read(ctx, func(state *memoryState) error {
+		matches := []SubscriptionToken{}
+		for _, subscriptionToken := range state.subscriptionTokens {
+			if filter.Token != "" && subscriptionToken.Token != filter.Token {
+				continue
+			}
+			if subscriptionIds != nil && !containsString(subscriptionIds, strconv.Itoa(subscriptionToken.SubscriptionId)) {
+				continue
+			}
+			if filter.Purpose != "" && subscriptionToken.Purpose != filter.Purpose {
+				continue
+			}
+			if !createdFrom.IsZero() && subscriptionToken.CreatedAt.Before(createdFrom) {
+				continue
+			}
+			if !createdTo.IsZero() && !subscriptionToken.CreatedAt.Before(createdTo) {
+				continue
+			}
+			matches = append(matches, subscriptionToken)
+		}
+
+		rows := make([]Cursor, len(matches))
+		for idx := range matches {
+			rows[idx] = Cursor{Id: matches[idx].Id}
+		}
+
+		indices, metadata, err := r.paginate(keys, filter.digest(), rows, filter.Cursor, filter.Page, perPage)
+		if err != nil {
+			return err
+		}
+
+		subscriptionTokens := make([]SubscriptionToken, len(indices))
+		for idx, match := range indices {
+			subscriptionTokens[idx] = matches[match]
+		}
+
+		response = &GetSubscriptionTokensResponse{
+			Data:     subscriptionTokens,
+			Metadata: metadata,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (r *MemoryRepository) CreateSubscriptionToken(ctx context.Context, payload CreateSubscriptionTokenPayload) (subscriptionToken *SubscriptionToken, err error) {
+	purpose := SubscriptionTokenPurposeManage
+	if payload.Purpose != nil {
+		purpose = *payload.Purpose
+	}
+
+	err = r.write(ctx, func(state *memoryState) error {
+		if state.subscriptionIndex(payload.SubscriptionId) < 0 {
+			return fmt.Errorf("subscription %d | %w", payload.SubscriptionId, ErrSubscriptionNotFound)
+		}
+
+		now := time.Now()
+		subscriptionToken = &SubscriptionToken{
+			Id:             state.nextId("subscription_token"),
+			SubscriptionId: payload.SubscriptionId,
+			Token:          generateToken(),
+			Purpose:        purpose,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		state.subscriptionTokens = append(state.subscriptionTokens, *subscriptionToken)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptionToken, nil
+}
+
+func (r *MemoryRepository) GetPendingNotifications(ctx context.Context, filter GetPendingNotificationsFilter) (notifications []Notification, err error) {
+	err = r.read(ctx, func(state *memoryState) error {
+		for _, notification := range state.notifications {
+			if notification.DeliveredAt != nil {
+				continue
+			}
+			if filter.FacilityId != 0 && notification.FacilityId != filter.FacilityId {
+				continue
+			}
+			notifications = append(notifications, notification)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (r *MemoryRepository) CreateNotifications(ctx context.Context, payloads []CreateNotificationPayload) error {
+	if len(payloads) <= 0 {
+		return nil
+	}
+
+	return r.write(ctx, func(state *memoryState) error {
+		now := time.Now()
+		for _, payload := range payloads {
+			if state.subscriptionIndex(payload.SubscriptionId) < 0 {
+				return fmt.Errorf("subscription %d does not exist", payload.SubscriptionId)
+			}
+			state.notifications = append(state.notifications, Notification{
+				Id:             state.nextId("notification"),
+				SubscriptionId: payload.SubscriptionId,
+				FacilityId:     payload.FacilityId,
+				CampsiteId:     payload.CampsiteId,
+				Date:           payload.Date,
+				PreviousStatus: payload.PreviousStatus,
+				Status:         payload.Status,
+				ObservedAt:     payload.ObservedAt,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			})
+		}
+		return nil
+	})
+}
+
+func (r *MemoryRepository) MarkNotificationsDelivered(ctx context.Context, ids []int) error {
+	if len(ids) <= 0 {
+		return nil
+	}
+
+	return r.write(ctx, func(state *memoryState) error {
+		now := time.Now()
+		for idx := range state.notifications {
+			notification := &state.notifications[idx]
+			if notification.DeliveredAt != nil || !containsInt(ids, notification.Id) {
+				continue
+			}
+			deliveredAt := now
+			notification.DeliveredAt = &deliveredAt
+			notification.UpdatedAt = now
+		}
+		return nil
+	})
+}
+
+// read runs fn against the transaction in ctx, or the committed state when
+// there is none.
+func (r *MemoryRepository) read(ctx context.Context, fn func(state *memoryState) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.state
+	if txn, ok := ctx.Value(TxnKey).(*memoryTxn); ok && txn != nil {
+		if txn.closed {
+			return fmt.Errorf("txn is already closed")
+		}
+		state = txn.state
+	}
+
+	return fn(state)
+}
+
+// write runs fn against a copy of the state and keeps the copy only when fn
+// succeeds. Outside a transaction it waits for any open one to end first.
+func (r *MemoryRepository) write(ctx context.Context, fn func(state *memoryState) error) error {
+	txn, ok := ctx.Value(TxnKey).(*memoryTxn)
+	if !ok || txn == nil {
+		if err := r.lockTxn(ctx); err != nil {
+			return fmt.Errorf("failed to wait for txn | %w", err)
+		}
+		defer r.unlockTxn()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	target := &r.state
+	if txn != nil {
+		if txn.closed {
+			return fmt.Errorf("txn is already closed")
+		}
+		target = &txn.state
+	}
+
+	state := (*target).clone()
+	if err := fn(state); err != nil {
+		return err
+	}
+	*target = state
+
+	return nil
+}
+
+// paginate orders rows by keys and picks the page asked for, the same way the
+// keyset and offset queries of Repository do. It returns the indices of the
+// rows on the page in order.
+func (r *MemoryRepository) paginate(keys keyset, filters string, rows []Cursor, encoded, page string, perPage int) ([]int, GetMetadata, error) {
+	var cursor *Cursor
+	if encoded != "" {
+		decoded, err := r.decodeCursor(encoded, keys.sort, filters)
+		if err != nil {
+			return nil, GetMetadata{}, err
+		}
+		cursor = decoded
+	}
+
+	pageNumber, err := parsePage(page)
+	if err != nil {
+		return nil, GetMetadata{}, err
+	}
+
+	backwards := cursor != nil && cursor.Prev
+	desc := keys.desc != backwards
+
+	indices := make([]int, 0, len(rows))
+	for idx := range rows {
+		if cursor != nil {
+			cmp := keys.compare(rows[idx], *cursor)
+			if (desc && cmp >= 0) || (!desc && cmp <= 0) {
+				continue
+			}
+		}
+		indices = append(indices, idx)
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		cmp := keys.compare(rows[indices[i]], rows[indices[j]])
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	if cursor == nil {
+		start, _ := pageBounds(len(indices), pageNumber, perPage)
+		indices = indices[start:]
+	}
+
+	more := len(indices) > perPage
+	if more {
+		indices = indices[:perPage]
+	}
+	if backwards {
+		for i, j := 0, len(indices)-1; i < j; i, j = i+1, j-1 {
+			indices[i], indices[j] = indices[j], indices[i]
+		}
+	}
+
+	metadata := newMetadata(pageNumber, perPage, len(rows))
+	if len(indices) > 0 {
+		first, last := rows[indices[0]], rows[indices[len(indices)-1]]
+		first.Sort, last.Sort = keys.sort, keys.sort
+		first.Filters, last.Filters = filters, filters
+		r.setCursors(&metadata, cursor, pageNumber, more, first, last)
+	}
+
+	return indices, metadata, nil
+}
+
+// compare orders two rows by the keyset columns, returning a negative number
+// when a comes first in ascending order.
+func (k keyset) compare(a, b Cursor) int {
+	for _, col := range k.cols {
+		var cmp int
+		switch col {
+		case "name":
+			cmp = strings.Compare(stringValue(a.Name), stringValue(b.Name))
+		case "created_at":
+			cmp = compareTimes(a.CreatedAt, b.CreatedAt)
+		default:
+			cmp = a.Id - b.Id
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+func (s *memoryState) clone() *memoryState {
+	sequences := make(map[string]int, len(s.sequences))
+	for name, value := range s.sequences {
+		sequences[name] = value
+	}
+
+	return &memoryState{
+		facilities:         append([]Facility(nil), s.facilities...),
+		facilityMedias:     append([]FacilityMedia(nil), s.facilityMedias...),
+		campsites:          append([]Campsite(nil), s.campsites...),
+		snapshots:          append([]AvailabilitySnapshot(nil), s.snapshots...),
+		subscriptions:      append([]Subscription(nil), s.subscriptions...),
+		subscriptionTokens: append([]SubscriptionToken(nil), s.subscriptionTokens...),
+		notifications:      append([]Notification(nil), s.notifications...),
+		sequences:          sequences,
+	}
+}
+
+func (s *memoryState) nextId(table string) int {
+	s.sequences[table]++
+	return s.sequences[table]
+}
+
+func (s *memoryState) facility(id int) *Facility {
+	for idx := range s.facilities {
+		if s.facilities[idx].Id == id {
+			facility := s.facilities[idx]
+			return &facility
+		}
+	}
+	return nil
+}
+
+func (s *memoryState) subscriptionIndex(id int) int {
+	for idx := range s.subscriptions {
+		if s.subscriptions[idx].Id == id {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (s *memoryState) setFacilityMedias(facilities []Facility) {
+	facilityMediasMap := make(map[string]string, len(facilities))
+	for _, media := range s.facilityMedias {
+		if media.IsPrimary && media.Url != nil {
+			facilityMediasMap[media.FacilityId] = *media.Url
+		}
+	}
+
+	for idx := range facilities {
+		facility := &facilities[idx]
+		primaryImg := facilityMediasMap[facility.FacilityId]
+		facility.PrimaryImg = &primaryImg
+	}
+}
+
+func isActiveSubscription(subscription Subscription) bool {
+	return subscription.Status == nil || *subscription.Status == SubscriptionStatusActive
+}
+
+// pageBounds returns the range of rows an OFFSET/LIMIT query would read.
+func pageBounds(total, page, size int) (start, end int) {
+	if page > 1 {
+		start = (page - 1) * size
+	}
+	if start > total {
+		start = total
+	}
+
+	end = start + size
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+// distance is the great circle distance between two points in meters.
+func distance(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadius = 6371000
+
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if strings.TrimSpace(v) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func compareTimes(a, b *time.Time) int {
+	switch {
+	case a == nil || b == nil:
+		return 0
+	case a.Before(*b):
+		return -1
+	case a.After(*b):
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,180 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/repositories"
+	"github.com/katakeda/lantrn-api-go/repositories/repotest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Harness {
+		repo := newMemoryRepository(t)
+		return repotest.Harness{
+			Repository:  repo,
+			AddFacility: repo.AddFacility,
+		}
+	})
+}
+
+func TestMemoryRepositoryConcurrentTransactions(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository(t)
+	facility, err := repo.AddFacility(ctx, repositories.Facility{Name: "Upper Pines", FacilityId: "232447"})
+	if err != nil {
+		t.Fatalf("AddFacility: %v", err)
+	}
+
+	const writers = 20
+	targetDate := time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02")
+	payload := repositories.CreateSubscriptionPayload{Email: "camper@example.com", TargetDate: targetDate, FacilityId: facility.Id}
+
+	// Half the subscriptions are created in transactions and half without,
+	// all at once. None may be lost or share an id.
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for idx := 0; idx < writers; idx++ {
+		wg.Add(1)
+		go func(inTxn bool) {
+			defer wg.Done()
+			if !inTxn {
+				_, err := repo.CreateSubscription(ctx, payload)
+				errs <- err
+				return
+			}
+			errs <- repo.WithTx(ctx, func(ctx context.Context) error {
+				_, err := repo.CreateSubscription(ctx, payload)
+				// Stay open long enough for the others to pile up.
+				time.Sleep(time.Millisecond)
+				return err
+			})
+		}(idx%2 == 0)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("CreateSubscription: %v", err)
+		}
+	}
+
+	response, err := repo.GetSubscriptions(ctx, repositories.GetSubscriptionsFilter{PerPage: strconv.Itoa(writers)})
+	if err != nil {
+		t.Fatalf("GetSubscriptions: %v", err)
+	}
+	if response.Metadata.Total != writers {
+		t.Errorf("stored %d subscriptions, want %d", response.Metadata.Total, writers)
+	}
+	ids := make(map[int]bool)
+	for _, subscription := range response.Data {
+		if ids[subscription.Id] {
+			t.Errorf("id %d was given out twice", subscription.Id)
+		}
+		ids[subscription.Id] = true
+	}
+}
+
+func TestMemoryRepositoryRollbackReleasesWriters(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository(t)
+
+	txCtx, err := repo.BeginTxn(ctx)
+	if err != nil {
+		t.Fatalf("BeginTxn: %v", err)
+	}
+
+	// A write outside the transaction waits for it to end, so it isn't
+	// overwritten by the commit.
+	done := make(chan error)
+	go func() {
+		_, err := repo.AddFacility(ctx, repositories.Facility{Name: "North Pines", FacilityId: "232449"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("AddFacility finished while a txn was open: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if _, err := repo.AddFacility(txCtx, repositories.Facility{Name: "Upper Pines", FacilityId: "232447"}); err != nil {
+		t.Fatalf("AddFacility in txn: %v", err)
+	}
+	if err := repo.RollbackTxn(txCtx); err != nil {
+		t.Fatalf("RollbackTxn: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("AddFacility: %v", err)
+	}
+
+	response, err := repo.GetFacilities(ctx, repositories.GetFacilitiesFilter{})
+	if err != nil {
+		t.Fatalf("GetFacilities: %v", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].Name != "North Pines" {
+		t.Errorf("facilities = %+v, want only North Pines", response.Data)
+	}
+}
+
+func TestMemoryRepositoryAbandonedTxnReleasesWriters(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository(t)
+
+	abandonedCtx, cancel := context.WithCancel(ctx)
+	txCtx, err := repo.BeginTxn(abandonedCtx)
+	if err != nil {
+		t.Fatalf("BeginTxn: %v", err)
+	}
+	if _, err := repo.AddFacility(txCtx, repositories.Facility{Name: "Upper Pines", FacilityId: "232447"}); err != nil {
+		t.Fatalf("AddFacility in txn: %v", err)
+	}
+
+	// Nobody waiting on the abandoned transaction gives up early.
+	waitCtx, stop := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer stop()
+	if _, err := repo.AddFacility(waitCtx, repositories.Facility{Name: "North Pines", FacilityId: "232449"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("AddFacility while a txn was open = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The transaction is never ended, only its context.
+	cancel()
+
+	waitCtx, stop = context.WithTimeout(ctx, time.Second)
+	defer stop()
+	if _, err := repo.AddFacility(waitCtx, repositories.Facility{Name: "North Pines", FacilityId: "232449"}); err != nil {
+		t.Fatalf("AddFacility: %v", err)
+	}
+	nextCtx, err := repo.BeginTxn(waitCtx)
+	if err != nil {
+		t.Fatalf("BeginTxn after an abandoned txn: %v", err)
+	}
+	if err := repo.RollbackTxn(nextCtx); err != nil {
+		t.Fatalf("RollbackTxn: %v", err)
+	}
+	if err := repo.CommitTxn(txCtx); err == nil {
+		t.Error("CommitTxn of the abandoned txn succeeded")
+	}
+
+	response, err := repo.GetFacilities(ctx, repositories.GetFacilitiesFilter{})
+	if err != nil {
+		t.Fatalf("GetFacilities: %v", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].Name != "North Pines" {
+		t.Errorf("facilities = %+v, want only North Pines", response.Data)
+	}
+}
+
+func newMemoryRepository(t *testing.T) *repositories.MemoryRepository {
+	t.Helper()
+
+	repo, err := repositories.NewMemoryRepository()
+	if err != nil {
+		t.Fatalf("NewMemoryRepository: %v", err)
+	}
+
+	return repo
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+// Notification is an opening owed to a subscription. Notifications are stored
+// with the snapshots that revealed them and marked delivered once the
+// subscriber was told, so a failed delivery is retried on its own.
+type Notification struct {
+	Id             int        `json:"id" db:"id"`
+	SubscriptionId int        `json:"subscriptionId" db:"subscription_id"`
+	FacilityId     int        `json:"facilityId" db:"facility_id"`
+	CampsiteId     *int       `json:"campsiteId" db:"campsite_id"`
+	Date           string     `json:"date" db:"date"`
+	PreviousStatus string     `json:"previousStatus" db:"previous_status"`
+	Status         string     `json:"status" db:"status"`
+	ObservedAt     time.Time  `json:"observedAt" db:"observed_at"`
+	DeliveredAt    *time.Time `json:"deliveredAt" db:"delivered_at"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+type GetPendingNotificationsFilter struct {
+	FacilityId int
+}
+
+type CreateNotificationPayload struct {
+	SubscriptionId int
+	FacilityId     int
+	CampsiteId     *int
+	Date           string
+	PreviousStatus string
+	Status         string
+	ObservedAt     time.Time
+}
+
+// GetPendingNotifications returns the notifications not delivered yet, oldest
+// first.
+func (r *Repository) GetPendingNotifications(ctx context.Context, filter GetPendingNotificationsFilter) (notifications []Notification, err error) {
+	db := r.reader(ctx)
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select(notificationCols()...).
+		From(`"notification"`).
+		Where(sq.Eq{"delivered_at": nil}).
+		OrderBy("id")
+
+	if filter.FacilityId != 0 {
+		psql = psql.Where(sq.Eq{"facility_id": filter.FacilityId})
+	}
+
+	sqlStmt, sqlArgs, err := psql.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	if err := pgxscan.ScanAll(&notifications, rows); err != nil {
+		return nil, fmt.Errorf("failed to scan rows | %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (r *Repository) CreateNotifications(ctx context.Context, payloads []CreateNotificationPayload) (err error) {
+	if len(payloads) <= 0 {
+		return nil
+	}
+
+	tx, endTxn, err := r.getTxn(ctx)
+	if err != nil {
+		return err
+	}
+	defer endTxn(&err)
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Insert(`"notification"`).
+		Columns("subscription_id", "facility_id", "campsite_id", "date", "previous_status", "status", "observed_at")
+
+	for _, payload := range payloads {
+		psql = psql.Values(payload.SubscriptionId, payload.FacilityId, payload.CampsiteId, payload.Date, payload.PreviousStatus, payload.Status, payload.ObservedAt)
+	}
+
+	sqlStmt, sqlArgs, err := psql.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	if _, err := tx.Exec(ctx, sqlStmt, sqlArgs...); err != nil {
+		return fmt.Errorf("failed to execute: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	return nil
+}
+
+// MarkNotificationsDelivered records that the notifications went out. Ones
+// already delivered keep their original time.
+func (r *Repository) MarkNotificationsDelivered(ctx context.Context, ids []int) (err error) {
+	if len(ids) <= 0 {
+		return nil
+	}
+
+	tx, endTxn, err := r.getTxn(ctx)
+	if err != nil {
+		return err
+	}
+	defer endTxn(&err)
+
+	sqlStmt, sqlArgs, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Update(`"notification"`).
+		Set("delivered_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": ids}).
+		Where(sq.Eq{"delivered_at": nil}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	if _, err := tx.Exec(ctx, sqlStmt, sqlArgs...); err != nil {
+		return fmt.Errorf("failed to execute: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	return nil
+}
+
+// notificationCols selects date as text for the same reason as
+// availabilitySnapshotCols.
+func notificationCols() []string {
+	return []string{
+		"id",
+		"subscription_id",
+		"facility_id",
+		"campsite_id",
+		`to_char("date", 'YYYY-MM-DD') AS "date"`,
+		"previous_status",
+		"status",
+		"observed_at",
+		"delivered_at",
+		"created_at",
+		"updated_at",
+	}
+}
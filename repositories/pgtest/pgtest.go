@@ -0,0 +1,364 @@
+//go:build integration
+
+// Package pgtest runs repositories against a throwaway Postgres database. It
+// connects to TEST_DATABASE_URL when set and creates a database of its own
+// there, otherwise it starts a server with the locally installed initdb and
+// pg_ctl in a temporary directory. Either way PostGIS must be available.
+//
+// Packages use it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		db, err := pgtest.Start(context.Background())
+//		...
+//		code := m.Run()
+//		db.Close()
+//		os.Exit(code)
+//	}
+package pgtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/katakeda/lantrn-api-go/migrations"
+	"github.com/katakeda/lantrn-api-go/repositories"
+	"github.com/katakeda/lantrn-api-go/repositories/repotest"
+)
+
+const TestDatabaseURLEnv = "TEST_DATABASE_URL"
+
+// fixtures are seeded into every database.
+var fixtures = []repositories.Facility{repotest.UpperPines, repotest.NorthPines, repotest.Blackwoods}
+
+type Database struct {
+	Pool       *pgxpool.Pool
+	Repository *repositories.Repository
+
+	stop func() error
+}
+
+// Start creates the database, applies every migration and seeds the
+// fixtures.
+func Start(ctx context.Context) (db *Database, err error) {
+	dsn, stop, err := createDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			stop()
+		}
+	}()
+
+	pool, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect with test DB | %w", err)
+	}
+
+	db = &Database{
+		Pool: pool,
+		stop: func() error {
+			pool.Close()
+			return stop()
+		},
+	}
+
+	if err := db.migrate(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	if err := db.seed(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	db.Repository, err = repositories.NewRepository(pool)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to initialize repository | %w", err)
+	}
+
+	return db, nil
+}
+
+// Close drops the database, or stops the server Start started.
+func (d *Database) Close() error {
+	return d.stop()
+}
+
+// Txn begins a transaction the way handlers do and rolls it back once the
+// test is over, so nothing a test writes is seen by the next one.
+func (d *Database) Txn(t testing.TB) context.Context {
+	t.Helper()
+
+	ctx, err := d.Repository.BeginTxn(context.Background())
+	if err != nil {
+		t.Fatalf("failed to begin txn: %v", err)
+	}
+	t.Cleanup(func() {
+		d.Repository.RollbackTxn(ctx)
+	})
+
+	return ctx
+}
+
+// Facility returns a seeded fixture by its upstream facility ID.
+func (d *Database) Facility(t testing.TB, facilityId string) repositories.Facility {
+	t.Helper()
+
+	var facility repositories.Facility
+	err := d.Pool.QueryRow(context.Background(), `SELECT id, name FROM "facility" WHERE facility_id = $1`, facilityId).
+		Scan(&facility.Id, &facility.Name)
+	if err != nil {
+		t.Fatalf("failed to get fixture facility %s: %v", facilityId, err)
+	}
+	facility.FacilityId = facilityId
+
+	return facility
+}
+
+// Harness empties the database and hands it to the conformance suite. The
+// suite commits its own transactions, so the data is truncated rather than
+// rolled back, and the fixtures are seeded again once the test is over.
+func (d *Database) Harness(t *testing.T) repotest.Harness {
+	t.Helper()
+
+	if err := d.truncate(context.Background()); err != nil {
+		t.Fatalf("%v", err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		if err := d.truncate(ctx); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if err := d.seed(ctx); err != nil {
+			t.Errorf("%v", err)
+		}
+	})
+
+	return repotest.Harness{
+		Repository:  d.Repository,
+		AddFacility: d.addFacility,
+	}
+}
+
+func (d *Database) truncate(ctx context.Context) error {
+	_, err := d.Pool.Exec(ctx, `TRUNCATE "facility", "facility_media", "campsite", "availability_snapshot", "subscription", "subscription_token", "notification" RESTART IDENTITY CASCADE`)
+	if err != nil {
+		return fmt.Errorf("failed to truncate tables | %w", err)
+	}
+	return nil
+}
+
+func (d *Database) addFacility(ctx context.Context, facility repositories.Facility) (*repositories.Facility, error) {
+	sqlStmt := `INSERT INTO "facility" (name, description, latitude, longitude, facility_id, geom)
+		VALUES ($1, $2, $3::float4, $4::float4, $5, ST_SetSRID(ST_MakePoint($4::float4, $3::float4), 4326))
+		RETURNING id, created_at, updated_at`
+
+	err := d.Pool.QueryRow(ctx, sqlStmt, facility.Name, facility.Description, facility.Latitude, facility.Longitude, facility.FacilityId).
+		Scan(&facility.Id, &facility.CreatedAt, &facility.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute: %s | %w", sqlStmt, err)
+	}
+
+	return &facility, nil
+}
+
+func (d *Database) seed(ctx context.Context) error {
+	for _, facility := range fixtures {
+		if _, err := d.addFacility(ctx, facility); err != nil {
+			return fmt.Errorf("failed to seed facility %s | %w", facility.Name, err)
+		}
+	}
+	return nil
+}
+
+// migrate applies the Up section of every migration in order and records it
+// in goose_db_version, as goose would.
+func (d *Database) migrate(ctx context.Context) error {
+	if _, err := d.Pool.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS postgis`); err != nil {
+		return fmt.Errorf("failed to create postgis extension | %w", err)
+	}
+
+	_, err := d.Pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS goose_db_version (
+		id serial PRIMARY KEY,
+		version_id bigint NOT NULL,
+		is_applied boolean NOT NULL,
+		tstamp timestamp DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create goose_db_version | %w", err)
+	}
+
+	names, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations | %w", err)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		content, err := fs.ReadFile(migrations.FS, name)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s | %w", name, err)
+		}
+
+		version, err := migrations.Version(name)
+		if err != nil {
+			return err
+		}
+
+		if _, err := d.Pool.Exec(ctx, upSection(string(content))); err != nil {
+			return fmt.Errorf("failed to apply migration %s | %w", name, err)
+		}
+		if _, err := d.Pool.Exec(ctx, `INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, true)`, version); err != nil {
+			return fmt.Errorf("failed to record migration %s | %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// upSection returns the statements between "-- +goose Up" and "-- +goose
+// Down". Without arguments pgx sends them in one simple query.
+func upSection(migration string) string {
+	var up []string
+	inUp := false
+	for _, line := range strings.Split(migration, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "-- +goose Up"):
+			inUp = true
+		case strings.HasPrefix(trimmed, "-- +goose Down"):
+			inUp = false
+		case strings.HasPrefix(trimmed, "-- +goose"):
+		case inUp:
+			up = append(up, line)
+		}
+	}
+	return strings.Join(up, "\n")
+}
+
+func createDatabase(ctx context.Context) (dsn string, stop func() error, err error) {
+	name := "lantrn_test_" + randomHex(6)
+
+	if baseURL := os.Getenv(TestDatabaseURLEnv); baseURL != "" {
+		return createDatabaseAt(ctx, baseURL, name)
+	}
+
+	return startServer(ctx, name)
+}
+
+func createDatabaseAt(ctx context.Context, baseURL, name string) (dsn string, stop func() error, err error) {
+	admin, err := pgxpool.Connect(ctx, baseURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to connect with %s | %w", TestDatabaseURLEnv, err)
+	}
+
+	if _, err := admin.Exec(ctx, fmt.Sprintf(`CREATE DATABASE %q`, name)); err != nil {
+		admin.Close()
+		return "", nil, fmt.Errorf("failed to create database %s | %w", name, err)
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		admin.Close()
+		return "", nil, fmt.Errorf("%s must be a URL | %w", TestDatabaseURLEnv, err)
+	}
+	u.Path = "/" + name
+
+	return u.String(), func() error {
+		defer admin.Close()
+		_, err := admin.Exec(context.Background(), fmt.Sprintf(`DROP DATABASE %q WITH (FORCE)`, name))
+		return err
+	}, nil
+}
+
+// startServer runs a server listening only on a unix socket inside a
+// temporary directory, which is removed when it is stopped.
+func startServer(ctx context.Context, name string) (dsn string, stop func() error, err error) {
+	for _, bin := range []string{"initdb", "pg_ctl"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			return "", nil, fmt.Errorf("%s not found, install Postgres or set %s | %w", bin, TestDatabaseURLEnv, err)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "pgtest")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp dir | %w", err)
+	}
+	dataDir := filepath.Join(dir, "data")
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	if out, err := exec.CommandContext(ctx, "initdb", "-D", dataDir, "-U", "postgres", "-A", "trust").CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("failed to run initdb: %s | %w", out, err)
+	}
+
+	options := fmt.Sprintf("-c listen_addresses='' -k %s -p %d -F", dir, port)
+	if out, err := exec.CommandContext(ctx, "pg_ctl", "-D", dataDir, "-o", options, "-w", "start").CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("failed to start postgres: %s | %w", out, err)
+	}
+
+	stopServer := func() error {
+		out, err := exec.Command("pg_ctl", "-D", dataDir, "-m", "immediate", "-w", "stop").CombinedOutput()
+		if err != nil {
+			err = fmt.Errorf("failed to stop postgres: %s | %w", out, err)
+		}
+		if removeErr := os.RemoveAll(dir); err == nil {
+			err = removeErr
+		}
+		return err
+	}
+
+	adminURL := fmt.Sprintf("postgres://postgres@/postgres?host=%s&port=%d", url.QueryEscape(dir), port)
+	dsn, dropDatabase, err := createDatabaseAt(ctx, adminURL, name)
+	if err != nil {
+		stopServer()
+		return "", nil, err
+	}
+
+	return dsn, func() error {
+		err := dropDatabase()
+		if stopErr := stopServer(); err == nil {
+			err = stopErr
+		}
+		return err
+	}, nil
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port | %w", err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "0"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,160 @@
+//go:build integration
+
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/repositories"
+	"github.com/katakeda/lantrn-api-go/repositories/pgtest"
+	"github.com/katakeda/lantrn-api-go/repositories/repotest"
+)
+
+var db *pgtest.Database
+
+func TestMain(m *testing.M) {
+	var err error
+	db, err = pgtest.Start(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start test database: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	if err := db.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to close test database: %v\n", err)
+	}
+	os.Exit(code)
+}
+
+func TestRepository(t *testing.T) {
+	repotest.Run(t, db.Harness)
+}
+
+// The tests below cover what only Postgres does, such as PostGIS searches and
+// column defaults. They run against the fixtures, each in its own rolled back
+// transaction unless it says otherwise.
+
+func TestGetFacilitiesNearby(t *testing.T) {
+	ctx := db.Txn(t)
+
+	response, err := db.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{
+		Lat: fmt.Sprint(*repotest.UpperPines.Latitude),
+		Lng: fmt.Sprint(*repotest.UpperPines.Longitude),
+	})
+	if err != nil {
+		t.Fatalf("GetFacilities: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for _, facility := range response.Data {
+		names[facility.Name] = true
+	}
+	if !names[repotest.UpperPines.Name] || !names[repotest.NorthPines.Name] {
+		t.Errorf("nearby facilities = %v, want %s and %s", names, repotest.UpperPines.Name, repotest.NorthPines.Name)
+	}
+	if names[repotest.Blackwoods.Name] {
+		t.Errorf("nearby facilities = %v, want %s left out", names, repotest.Blackwoods.Name)
+	}
+	if response.Metadata.Total != 2 {
+		t.Errorf("total = %d, want 2", response.Metadata.Total)
+	}
+}
+
+func TestCreateSubscription(t *testing.T) {
+	ctx := db.Txn(t)
+	facility := db.Facility(t, repotest.UpperPines.FacilityId)
+
+	subscription, err := db.Repository.CreateSubscription(ctx, repositories.CreateSubscriptionPayload{
+		Email:       "camper@example.com",
+		TargetDate:  time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02"),
+		FacilityId:  facility.Id,
+		CampsiteIds: []int{},
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	if subscription.Id == 0 || subscription.FacilityId != facility.Id || subscription.CreatedAt.IsZero() {
+		t.Errorf("subscription = %+v, want the persisted row", subscription)
+	}
+	if subscription.Status == nil || *subscription.Status != repositories.SubscriptionStatusActive {
+		t.Errorf("status = %v, want the column default %q", subscription.Status, repositories.SubscriptionStatusActive)
+	}
+}
+
+func TestCreateSubscriptionMissingFacility(t *testing.T) {
+	ctx := db.Txn(t)
+
+	_, err := db.Repository.CreateSubscription(ctx, repositories.CreateSubscriptionPayload{
+		Email:      "camper@example.com",
+		TargetDate: time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02"),
+		FacilityId: -1,
+	})
+	if !errors.Is(err, repositories.ErrFacilityNotFound) {
+		t.Errorf("CreateSubscription = %v, want ErrFacilityNotFound", err)
+	}
+}
+
+func TestCreateSubscriptionToken(t *testing.T) {
+	ctx := db.Txn(t)
+	facility := db.Facility(t, repotest.NorthPines.FacilityId)
+
+	subscription, err := db.Repository.CreateSubscription(ctx, repositories.CreateSubscriptionPayload{
+		Email:      "camper@example.com",
+		TargetDate: time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02"),
+		FacilityId: facility.Id,
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	token, err := db.Repository.CreateSubscriptionToken(ctx, repositories.CreateSubscriptionTokenPayload{SubscriptionId: subscription.Id})
+	if err != nil {
+		t.Fatalf("CreateSubscriptionToken: %v", err)
+	}
+	if token.SubscriptionId != subscription.Id || len(token.Token) != 64 || token.Purpose != repositories.SubscriptionTokenPurposeManage {
+		t.Errorf("token = %+v, want the persisted row", token)
+	}
+
+	response, err := db.Repository.GetSubscriptionTokens(ctx, repositories.GetSubscriptionTokensFilter{Token: token.Token})
+	if err != nil {
+		t.Fatalf("GetSubscriptionTokens: %v", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].Id != token.Id {
+		t.Errorf("tokens = %+v, want the created token", response.Data)
+	}
+}
+
+func TestMigrationVersionIgnoresRolledBack(t *testing.T) {
+	ctx := context.Background()
+
+	latest, err := db.Repository.MigrationVersion(ctx)
+	if err != nil {
+		t.Fatalf("MigrationVersion: %v", err)
+	}
+
+	// Goose rolls the newest migration back by recording it as not
+	// applied. MigrationVersion reads the primary, so the row is committed
+	// and removed afterwards.
+	var id int
+	err = db.Pool.QueryRow(ctx, `INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, false) RETURNING id`, latest).Scan(&id)
+	if err != nil {
+		t.Fatalf("failed to record rollback: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Pool.Exec(context.Background(), `DELETE FROM goose_db_version WHERE id = $1`, id)
+	})
+
+	version, err := db.Repository.MigrationVersion(ctx)
+	if err != nil {
+		t.Fatalf("MigrationVersion: %v", err)
+	}
+	if version == 0 || version >= latest {
+		t.Errorf("MigrationVersion = %d, want the migration before %d", version, latest)
+	}
+}
@@ -0,0 +1,16 @@
+package repotest
+
+import "github.com/katakeda/lantrn-api-go/repositories"
+
+// Fixture facilities shared by the handler and Postgres tests. Upper Pines and
+// North Pines are a few kilometers apart in Yosemite, Blackwoods is across the
+// country.
+var (
+	UpperPines = repositories.Facility{Name: "Upper Pines", FacilityId: "232447", Latitude: Float32Ptr(37.7365), Longitude: Float32Ptr(-119.5617)}
+	NorthPines = repositories.Facility{Name: "North Pines", FacilityId: "232449", Latitude: Float32Ptr(37.7394), Longitude: Float32Ptr(-119.5661)}
+	Blackwoods = repositories.Facility{Name: "Blackwoods Campground", FacilityId: "232508", Latitude: Float32Ptr(44.3094), Longitude: Float32Ptr(-68.2037)}
+)
+
+func Float32Ptr(value float32) *float32 {
+	return &value
+}
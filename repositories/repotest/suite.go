@@ -0,0 +1,533 @@
+// Package repotest is a conformance suite for repositories.IRepository. Every
+// implementation is expected to pass it, which is what lets handlers be
+// tested against the in-memory repository and trusted against Postgres.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/katakeda/lantrn-api-go/errs"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+const dateLayout = "2006-01-02"
+
+// Harness is an empty repository along with what the suite needs to seed it.
+type Harness struct {
+	Repository repositories.IRepository
+
+	// AddFacility stores a facility. IRepository has no way to create one.
+	AddFacility func(ctx context.Context, facility repositories.Facility) (*repositories.Facility, error)
+}
+
+// Run runs the suite. newHarness is called for every test and must return a
+// repository holding no data.
+func Run(t *testing.T, newHarness func(t *testing.T) Harness) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, h Harness)
+	}{
+		{"Facilities", testFacilities},
+		{"FacilityCursors", testFacilityCursors},
+		{"Campsites", testCampsites},
+		{"Availability", testAvailability},
+		{"Subscriptions", testSubscriptions},
+		{"SubscriptionTokens", testSubscriptionTokens},
+		{"Notifications", testNotifications},
+		{"Transactions", testTransactions},
+		{"WithTx", testWithTx},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newHarness(t))
+		})
+	}
+}
+
+func testFacilities(t *testing.T, h Harness) {
+	ctx := context.Background()
+	seedFacilities(t, h, "Bear Lake", "Aspen Grove", "Cedar Flats")
+
+	response, err := h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{})
+	if err != nil {
+		t.Fatalf("GetFacilities: %v", err)
+	}
+	assertNames(t, response.Data, "Aspen Grove", "Bear Lake", "Cedar Flats")
+	if response.Metadata.Total != 3 {
+		t.Errorf("total = %d, want 3", response.Metadata.Total)
+	}
+
+	response, err = h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{Sort: "za", PerPage: "2", Page: "2"})
+	if err != nil {
+		t.Fatalf("GetFacilities page 2: %v", err)
+	}
+	assertNames(t, response.Data, "Aspen Grove")
+	if response.Metadata.HasNext {
+		t.Error("last page reports a next page")
+	}
+
+	facility, err := h.Repository.GetFacility(ctx, "0")
+	if !errors.Is(err, repositories.ErrFacilityNotFound) {
+		t.Errorf("GetFacility missing = %v, %v, want ErrFacilityNotFound", facility, err)
+	}
+
+	_, err = h.Repository.GetFacility(ctx, "abc")
+	assertCode(t, err, errs.CodeInvalidArgument)
+
+	_, err = h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{Fields: "nope"})
+	assertCode(t, err, errs.CodeInvalidArgument)
+
+	for _, filter := range []repositories.GetFacilitiesFilter{
+		{Lat: "north", Lng: "-119.5617"},
+		{Lat: "37.7365", Lng: "west"},
+		{Lat: "91", Lng: "-119.5617"},
+		{Lat: "37.7365", Lng: "-181"},
+	} {
+		_, err = h.Repository.GetFacilities(ctx, filter)
+		assertCode(t, err, errs.CodeInvalidArgument)
+	}
+}
+
+func testFacilityCursors(t *testing.T, h Harness) {
+	ctx := context.Background()
+	facilities := seedFacilities(t, h, "Aspen Grove", "Bear Lake", "Cedar Flats", "Dune Camp", "Elk Meadow")
+
+	first, err := h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{PerPage: "2"})
+	if err != nil {
+		t.Fatalf("GetFacilities: %v", err)
+	}
+	assertNames(t, first.Data, "Aspen Grove", "Bear Lake")
+	if first.Metadata.Next == "" {
+		t.Fatal("first page has no next cursor")
+	}
+
+	second, err := h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{PerPage: "2", Cursor: first.Metadata.Next})
+	if err != nil {
+		t.Fatalf("GetFacilities next: %v", err)
+	}
+	assertNames(t, second.Data, "Cedar Flats", "Dune Camp")
+	if second.Metadata.Prev == "" {
+		t.Fatal("second page has no prev cursor")
+	}
+
+	back, err := h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{PerPage: "2", Cursor: second.Metadata.Prev})
+	if err != nil {
+		t.Fatalf("GetFacilities prev: %v", err)
+	}
+	assertNames(t, back.Data, "Aspen Grove", "Bear Lake")
+
+	_, err = h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{Sort: "za", Cursor: first.Metadata.Next})
+	assertCode(t, err, errs.CodeInvalidArgument)
+
+	_, err = h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{Cursor: first.Metadata.Next + "x"})
+	assertCode(t, err, errs.CodeInvalidArgument)
+
+	// A cursor only pages through the filtered listing that issued it.
+	ids := fmt.Sprintf("%d,%d,%d", facilities[1].Id, facilities[3].Id, facilities[4].Id)
+	_, err = h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{PerPage: "2", Ids: ids, Cursor: first.Metadata.Next})
+	assertCode(t, err, errs.CodeInvalidArgument)
+
+	filtered, err := h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{PerPage: "2", Ids: ids})
+	if err != nil {
+		t.Fatalf("GetFacilities filtered: %v", err)
+	}
+	assertNames(t, filtered.Data, "Bear Lake", "Dune Camp")
+
+	filteredNext, err := h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{PerPage: "2", Ids: ids, Cursor: filtered.Metadata.Next})
+	if err != nil {
+		t.Fatalf("GetFacilities filtered next: %v", err)
+	}
+	assertNames(t, filteredNext.Data, "Elk Meadow")
+
+	_, err = h.Repository.GetFacilities(ctx, repositories.GetFacilitiesFilter{PerPage: "2", Cursor: filtered.Metadata.Next})
+	assertCode(t, err, errs.CodeInvalidArgument)
+}
+
+func testCampsites(t *testing.T, h Harness) {
+	ctx := context.Background()
+	facility := seedFacilities(t, h, "Aspen Grove")[0]
+
+	loop := "A"
+	campsites, err := h.Repository.UpsertCampsites(ctx, []repositories.UpsertCampsitePayload{
+		{Name: "002", Loop: &loop, CampsiteId: "1002", FacilityId: facility.Id},
+		{Name: "001", Loop: &loop, CampsiteId: "1001", FacilityId: facility.Id},
+	})
+	if err != nil {
+		t.Fatalf("UpsertCampsites: %v", err)
+	}
+	if len(campsites) != 2 {
+		t.Fatalf("UpsertCampsites returned %d campsites, want 2", len(campsites))
+	}
+
+	campsites, err = h.Repository.UpsertCampsites(ctx, []repositories.UpsertCampsitePayload{
+		{Name: "001 renamed", Loop: &loop, CampsiteId: "1001", FacilityId: facility.Id},
+	})
+	if err != nil {
+		t.Fatalf("UpsertCampsites existing: %v", err)
+	}
+	if campsites[0].Name != "001 renamed" {
+		t.Errorf("upserted name = %q, want %q", campsites[0].Name, "001 renamed")
+	}
+
+	all, err := h.Repository.GetFacilityCampsites(ctx, facility.Id)
+	if err != nil {
+		t.Fatalf("GetFacilityCampsites: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("facility has %d campsites, want 2", len(all))
+	}
+
+	response, err := h.Repository.GetCampsites(ctx, repositories.GetCampsitesFilter{FacilityId: strconv.Itoa(facility.Id), Loop: "A"})
+	if err != nil {
+		t.Fatalf("GetCampsites: %v", err)
+	}
+	if len(response.Data) != 2 || response.Data[0].Name != "001 renamed" {
+		t.Errorf("GetCampsites = %+v, want both campsites ordered by name", response.Data)
+	}
+}
+
+func testAvailability(t *testing.T, h Harness) {
+	ctx := context.Background()
+	facility := seedFacilities(t, h, "Aspen Grove")[0]
+
+	campsites, err := h.Repository.UpsertCampsites(ctx, []repositories.UpsertCampsitePayload{
+		{Name: "001", CampsiteId: "1001", FacilityId: facility.Id},
+	})
+	if err != nil {
+		t.Fatalf("UpsertCampsites: %v", err)
+	}
+
+	date := time.Now().UTC().AddDate(0, 0, 10).Format(dateLayout)
+	observed := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	err = h.Repository.CreateAvailabilitySnapshots(ctx, []repositories.CreateAvailabilitySnapshotPayload{
+		{FacilityId: facility.Id, CampsiteId: &campsites[0].Id, Date: date, Status: repositories.AvailabilityStatusReserved, ObservedAt: observed},
+		{FacilityId: facility.Id, CampsiteId: &campsites[0].Id, Date: date, Status: repositories.AvailabilityStatusAvailable, ObservedAt: observed.Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("CreateAvailabilitySnapshots: %v", err)
+	}
+
+	filter := repositories.GetAvailabilityFilter{FacilityId: strconv.Itoa(facility.Id), From: date, To: date}
+
+	latest, err := h.Repository.GetLatestAvailabilitySnapshots(ctx, filter)
+	if err != nil {
+		t.Fatalf("GetLatestAvailabilitySnapshots: %v", err)
+	}
+	if len(latest) != 1 || latest[0].Status != repositories.AvailabilityStatusAvailable {
+		t.Errorf("latest snapshots = %+v, want one available", latest)
+	}
+
+	history, err := h.Repository.GetAvailabilityHistory(ctx, filter)
+	if err != nil {
+		t.Fatalf("GetAvailabilityHistory: %v", err)
+	}
+	if history.Summary.Openings != 1 {
+		t.Errorf("openings = %d, want 1", history.Summary.Openings)
+	}
+
+	_, err = h.Repository.GetAvailability(ctx, repositories.GetAvailabilityFilter{FacilityId: strconv.Itoa(facility.Id), From: "tomorrow"})
+	assertCode(t, err, errs.CodeInvalidArgument)
+}
+
+func testSubscriptions(t *testing.T, h Harness) {
+	ctx := context.Background()
+	facility := seedFacilities(t, h, "Aspen Grove")[0]
+	targetDate := time.Now().UTC().AddDate(0, 0, 7).Format(dateLayout)
+
+	created, err := h.Repository.CreateSubscription(ctx, repositories.CreateSubscriptionPayload{
+		Email:      "camper@example.com",
+		TargetDate: targetDate,
+		FacilityId: facility.Id,
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	if created.Id == 0 || created.Email != "camper@example.com" || created.FacilityId != facility.Id {
+		t.Errorf("created subscription = %+v, want the persisted row", created)
+	}
+	if created.Status == nil || *created.Status != repositories.SubscriptionStatusActive {
+		t.Errorf("created status = %v, want %q", created.Status, repositories.SubscriptionStatusActive)
+	}
+	if created.CreatedAt.IsZero() {
+		t.Error("created subscription has no creation time")
+	}
+
+	_, err = h.Repository.CreateSubscription(ctx, repositories.CreateSubscriptionPayload{
+		Email:      "camper@example.com",
+		TargetDate: targetDate,
+		FacilityId: facility.Id + 1000,
+	})
+	if !errors.Is(err, repositories.ErrFacilityNotFound) {
+		t.Errorf("CreateSubscription missing facility = %v, want ErrFacilityNotFound", err)
+	}
+
+	paused := repositories.SubscriptionStatusPaused
+	updated, err := h.Repository.UpdateSubscription(ctx, strconv.Itoa(created.Id), repositories.UpdateSubscriptionPayload{Status: &paused})
+	if err != nil {
+		t.Fatalf("UpdateSubscription: %v", err)
+	}
+	if updated.Status == nil || *updated.Status != paused || updated.Email != created.Email {
+		t.Errorf("updated subscription = %+v, want the full row paused", updated)
+	}
+
+	_, err = h.Repository.UpdateSubscription(ctx, strconv.Itoa(created.Id+1000), repositories.UpdateSubscriptionPayload{Status: &paused})
+	if !errors.Is(err, repositories.ErrSubscriptionNotFound) {
+		t.Errorf("UpdateSubscription missing = %v, want ErrSubscriptionNotFound", err)
+	}
+
+	found, err := h.Repository.GetSubscription(ctx, strconv.Itoa(created.Id))
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if found.Status == nil || *found.Status != paused {
+		t.Errorf("GetSubscription status = %v, want %q", found.Status, paused)
+	}
+
+	active, err := h.Repository.GetActiveSubscriptions(ctx, repositories.GetActiveSubscriptionsFilter{FacilityId: facility.Id})
+	if err != nil {
+		t.Fatalf("GetActiveSubscriptions: %v", err)
+	}
+	if len(active) != 0 {
+		t.Errorf("active subscriptions = %d, want 0 once paused", len(active))
+	}
+
+	response, err := h.Repository.GetSubscriptions(ctx, repositories.GetSubscriptionsFilter{
+		Status:      paused,
+		CreatedFrom: time.Now().UTC().AddDate(0, 0, -1).Format(dateLayout),
+	})
+	if err != nil {
+		t.Fatalf("GetSubscriptions: %v", err)
+	}
+	if len(response.Data) != 1 || response.Metadata.Total != 1 {
+		t.Errorf("GetSubscriptions = %d rows, total %d, want 1", len(response.Data), response.Metadata.Total)
+	}
+
+	_, err = h.Repository.GetSubscriptions(ctx, repositories.GetSubscriptionsFilter{CreatedTo: "last week"})
+	assertCode(t, err, errs.CodeInvalidArgument)
+}
+
+func testSubscriptionTokens(t *testing.T, h Harness) {
+	ctx := context.Background()
+	facility := seedFacilities(t, h, "Aspen Grove")[0]
+
+	subscription, err := h.Repository.CreateSubscription(ctx, repositories.CreateSubscriptionPayload{
+		Email:      "camper@example.com",
+		TargetDate: time.Now().UTC().AddDate(0, 0, 7).Format(dateLayout),
+		FacilityId: facility.Id,
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	token, err := h.Repository.CreateSubscriptionToken(ctx, repositories.CreateSubscriptionTokenPayload{SubscriptionId: subscription.Id})
+	if err != nil {
+		t.Fatalf("CreateSubscriptionToken: %v", err)
+	}
+	if token.SubscriptionId != subscription.Id || token.Token == "" || token.Purpose != repositories.SubscriptionTokenPurposeManage {
+		t.Errorf("created token = %+v, want the persisted row", token)
+	}
+
+	_, err = h.Repository.CreateSubscriptionToken(ctx, repositories.CreateSubscriptionTokenPayload{SubscriptionId: subscription.Id + 1000})
+	if !errors.Is(err, repositories.ErrSubscriptionNotFound) {
+		t.Errorf("CreateSubscriptionToken missing subscription = %v, want ErrSubscriptionNotFound", err)
+	}
+
+	response, err := h.Repository.GetSubscriptionTokens(ctx, repositories.GetSubscriptionTokensFilter{Token: token.Token})
+	if err != nil {
+		t.Fatalf("GetSubscriptionTokens: %v", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].Id != token.Id {
+		t.Errorf("GetSubscriptionTokens = %+v, want the created token", response.Data)
+	}
+}
+
+func testNotifications(t *testing.T, h Harness) {
+	ctx := context.Background()
+	facilities := seedFacilities(t, h, "Aspen Grove", "Bear Lake")
+	targetDate := time.Now().UTC().AddDate(0, 0, 7).Format(dateLayout)
+
+	subscription, err := h.Repository.CreateSubscription(ctx, repositories.CreateSubscriptionPayload{
+		Email:      "camper@example.com",
+		TargetDate: targetDate,
+		FacilityId: facilities[0].Id,
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	observedAt := time.Now().UTC().Truncate(time.Second)
+	payload := repositories.CreateNotificationPayload{
+		SubscriptionId: subscription.Id,
+		FacilityId:     facilities[0].Id,
+		Date:           targetDate,
+		PreviousStatus: repositories.AvailabilityStatusReserved,
+		Status:         repositories.AvailabilityStatusAvailable,
+		ObservedAt:     observedAt,
+	}
+	if err := h.Repository.CreateNotifications(ctx, []repositories.CreateNotificationPayload{payload, payload}); err != nil {
+		t.Fatalf("CreateNotifications: %v", err)
+	}
+
+	pending, err := h.Repository.GetPendingNotifications(ctx, repositories.GetPendingNotificationsFilter{FacilityId: facilities[0].Id})
+	if err != nil {
+		t.Fatalf("GetPendingNotifications: %v", err)
+	}
+	if len(pending) != 2 || pending[0].Id >= pending[1].Id {
+		t.Fatalf("pending notifications = %+v, want both, oldest first", pending)
+	}
+	if got := pending[0]; got.SubscriptionId != subscription.Id || got.Date != targetDate || got.Status != payload.Status || got.PreviousStatus != payload.PreviousStatus || !got.ObservedAt.Equal(observedAt) || got.DeliveredAt != nil {
+		t.Errorf("pending notification = %+v, want the stored payload", got)
+	}
+
+	if err := h.Repository.MarkNotificationsDelivered(ctx, []int{pending[0].Id}); err != nil {
+		t.Fatalf("MarkNotificationsDelivered: %v", err)
+	}
+	left, err := h.Repository.GetPendingNotifications(ctx, repositories.GetPendingNotificationsFilter{FacilityId: facilities[0].Id})
+	if err != nil {
+		t.Fatalf("GetPendingNotifications: %v", err)
+	}
+	if len(left) != 1 || left[0].Id != pending[1].Id {
+		t.Errorf("pending after delivery = %+v, want only the second", left)
+	}
+
+	other, err := h.Repository.GetPendingNotifications(ctx, repositories.GetPendingNotificationsFilter{FacilityId: facilities[1].Id})
+	if err != nil {
+		t.Fatalf("GetPendingNotifications: %v", err)
+	}
+	if len(other) != 0 {
+		t.Errorf("pending for another facility = %+v, want none", other)
+	}
+}
+
+func testTransactions(t *testing.T, h Harness) {
+	ctx := context.Background()
+	facility := seedFacilities(t, h, "Aspen Grove")[0]
+	payload := repositories.CreateSubscriptionPayload{
+		Email:      "camper@example.com",
+		TargetDate: time.Now().UTC().AddDate(0, 0, 7).Format(dateLayout),
+		FacilityId: facility.Id,
+	}
+
+	txCtx, err := h.Repository.BeginTxn(ctx)
+	if err != nil {
+		t.Fatalf("BeginTxn: %v", err)
+	}
+	rolledBack, err := h.Repository.CreateSubscription(txCtx, payload)
+	if err != nil {
+		t.Fatalf("CreateSubscription in txn: %v", err)
+	}
+	if _, err := h.Repository.GetSubscription(txCtx, strconv.Itoa(rolledBack.Id)); err != nil {
+		t.Errorf("subscription isn't visible inside its txn: %v", err)
+	}
+	if _, err := h.Repository.GetSubscription(ctx, strconv.Itoa(rolledBack.Id)); !errors.Is(err, repositories.ErrSubscriptionNotFound) {
+		t.Errorf("uncommitted subscription is visible outside its txn: %v", err)
+	}
+	if err := h.Repository.RollbackTxn(txCtx); err != nil {
+		t.Fatalf("RollbackTxn: %v", err)
+	}
+	if _, err := h.Repository.GetSubscription(ctx, strconv.Itoa(rolledBack.Id)); !errors.Is(err, repositories.ErrSubscriptionNotFound) {
+		t.Errorf("rolled back subscription = %v, want ErrSubscriptionNotFound", err)
+	}
+
+	txCtx, err = h.Repository.BeginTxn(ctx)
+	if err != nil {
+		t.Fatalf("BeginTxn: %v", err)
+	}
+	committed, err := h.Repository.CreateSubscription(txCtx, payload)
+	if err != nil {
+		t.Fatalf("CreateSubscription in txn: %v", err)
+	}
+	if err := h.Repository.CommitTxn(txCtx); err != nil {
+		t.Fatalf("CommitTxn: %v", err)
+	}
+	if _, err := h.Repository.GetSubscription(ctx, strconv.Itoa(committed.Id)); err != nil {
+		t.Errorf("committed subscription isn't visible: %v", err)
+	}
+	if err := h.Repository.CommitTxn(txCtx); err == nil {
+		t.Error("committing a closed txn succeeded")
+	}
+}
+
+func testWithTx(t *testing.T, h Harness) {
+	ctx := context.Background()
+	facility := seedFacilities(t, h, "Aspen Grove")[0]
+	payload := repositories.CreateSubscriptionPayload{
+		Email:      "camper@example.com",
+		TargetDate: time.Now().UTC().AddDate(0, 0, 7).Format(dateLayout),
+		FacilityId: facility.Id,
+	}
+
+	failure := errors.New("failed after writing")
+	var rolledBack *repositories.Subscription
+	err := h.Repository.WithTx(ctx, func(ctx context.Context) (err error) {
+		rolledBack, err = h.Repository.CreateSubscription(ctx, payload)
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithTx = %v, want the error fn returned", err)
+	}
+	if _, err := h.Repository.GetSubscription(ctx, strconv.Itoa(rolledBack.Id)); !errors.Is(err, repositories.ErrSubscriptionNotFound) {
+		t.Errorf("subscription written by a failed WithTx = %v, want ErrSubscriptionNotFound", err)
+	}
+
+	var committed *repositories.Subscription
+	err = h.Repository.WithTx(ctx, func(ctx context.Context) (err error) {
+		committed, err = h.Repository.CreateSubscription(ctx, payload)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if _, err := h.Repository.GetSubscription(ctx, strconv.Itoa(committed.Id)); err != nil {
+		t.Errorf("subscription written by WithTx isn't visible: %v", err)
+	}
+}
+
+func seedFacilities(t *testing.T, h Harness, names ...string) []repositories.Facility {
+	t.Helper()
+
+	facilities := make([]repositories.Facility, len(names))
+	for idx, name := range names {
+		facility, err := h.AddFacility(context.Background(), repositories.Facility{
+			Name:       name,
+			FacilityId: strconv.Itoa(232000 + idx),
+		})
+		if err != nil {
+			t.Fatalf("AddFacility %q: %v", name, err)
+		}
+		facilities[idx] = *facility
+	}
+	return facilities
+}
+
+func assertNames(t *testing.T, facilities []repositories.Facility, names ...string) {
+	t.Helper()
+
+	if len(facilities) != len(names) {
+		t.Fatalf("got %d facilities, want %v", len(facilities), names)
+	}
+	for idx, name := range names {
+		if facilities[idx].Name != name {
+			t.Errorf("facility %d = %q, want %q", idx, facilities[idx].Name, name)
+		}
+	}
+}
+
+func assertCode(t *testing.T, err error, code errs.Code) {
+	t.Helper()
+
+	var e *errs.Error
+	if !errors.As(err, &e) || e.Code != code {
+		t.Errorf("error = %v, want code %q", err, code)
+	}
+}
@@ -5,24 +5,50 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/georgysavva/scany/pgxscan"
-	"github.com/jackc/pgx/v4"
+	"github.com/katakeda/lantrn-api-go/errs"
+)
+
+const (
+	SubscriptionStatusActive    = "active"
+	SubscriptionStatusPaused    = "paused"
+	SubscriptionStatusCancelled = "cancelled"
 )
 
 type Subscription struct {
-	Id         int     `json:"id" db:"id"`
-	Email      string  `json:"email" db:"email"`
-	TargetDate string  `json:"targetDate" db:"target_date"`
-	FacilityId int     `json:"facilityId" db:"facility_id"`
-	Status     *string `json:"status" db:"status"`
+	Id          int       `json:"id" db:"id"`
+	Email       string    `json:"email" db:"email"`
+	TargetDate  string    `json:"targetDate" db:"target_date"`
+	FacilityId  int       `json:"facilityId" db:"facility_id"`
+	CampsiteIds []int     `json:"campsiteIds" db:"campsite_ids"`
+	SiteType    *string   `json:"siteType" db:"site_type"`
+	Status      *string   `json:"status" db:"status"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
 }
 
 type GetSubscriptionsFilter struct {
 	FacilityIds string
 	Status      string
+	CreatedFrom string
+	CreatedTo   string
+	Sort        string
 	Page        string
+	PerPage     string
+	Cursor      string
+	Fields      string
+}
+
+func (f GetSubscriptionsFilter) digest() string {
+	return filterDigest("facility_ids", f.FacilityIds, "status", f.Status, "created_from", f.CreatedFrom, "created_to", f.CreatedTo)
+}
+
+type GetActiveSubscriptionsFilter struct {
+	FacilityId  int
+	TargetDates []string
 }
 
 type GetSubscriptionsResponse struct {
@@ -31,10 +57,12 @@ type GetSubscriptionsResponse struct {
 }
 
 type CreateSubscriptionPayload struct {
-	Email      string  `json:"email"`
-	TargetDate string  `json:"targetDate"`
-	FacilityId int     `json:"facilityId"`
-	Status     *string `json:"status"`
+	Email       string  `json:"email"`
+	TargetDate  string  `json:"targetDate"`
+	FacilityId  int     `json:"facilityId"`
+	CampsiteIds []int   `json:"campsiteIds"`
+	SiteType    *string `json:"siteType"`
+	Status      *string `json:"status"`
 }
 
 type UpdateSubscriptionPayload struct {
@@ -42,23 +70,18 @@ type UpdateSubscriptionPayload struct {
 }
 
 func (r *Repository) GetSubscriptions(ctx context.Context, filter GetSubscriptionsFilter) (response *GetSubscriptionsResponse, err error) {
-	tx, ok := ctx.Value(TxnKey).(pgx.Tx)
-	if !ok || tx == nil {
-		tx, _ = r.db.Begin(ctx)
-		defer func() error {
-			if err != nil {
-				return tx.Rollback(ctx)
-			}
-			return tx.Commit(ctx)
-		}()
+	db := r.reader(ctx)
+
+	keys := subscriptionKeyset(filter.Sort)
+
+	cols, _, err := selectColumns(filter.Fields, subscriptionColumns, nil, keys.cols...)
+	if err != nil {
+		return nil, err
 	}
 
-	cols := []string{
-		"id",
-		"email",
-		"target_date",
-		"facility_id",
-		"status",
+	perPage, err := parsePerPage(filter.PerPage, r.defaultPerPage, r.maxPerPage)
+	if err != nil {
+		return nil, err
 	}
 
 	countSql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
@@ -67,7 +90,7 @@ func (r *Repository) GetSubscriptions(ctx context.Context, filter GetSubscriptio
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
 		Select(cols...).
 		From(`"subscription"`).
-		Limit(perPageMax)
+		Limit(uint64(perPage) + 1)
 
 	if filter.Status != "" {
 		countSql = countSql.Where(sq.Eq{"status": filter.Status})
@@ -80,13 +103,40 @@ func (r *Repository) GetSubscriptions(ctx context.Context, filter GetSubscriptio
 		psql = psql.Where(sq.Eq{"facility_id": facilityIds})
 	}
 
-	offset := 0
-	if filter.Page != "" {
-		offset, err = strconv.Atoi(filter.Page)
+	if filter.CreatedFrom != "" {
+		createdFrom, err := parseTime(filter.CreatedFrom)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse query | %w", err)
+			return nil, errs.InvalidArgument("Created from must be a date or timestamp", err)
 		}
-		psql = psql.Offset(uint64(offset-1) * perPageMax)
+		countSql = countSql.Where(sq.GtOrEq{"created_at": createdFrom})
+		psql = psql.Where(sq.GtOrEq{"created_at": createdFrom})
+	}
+
+	if filter.CreatedTo != "" {
+		createdTo, err := parseTime(filter.CreatedTo)
+		if err != nil {
+			return nil, errs.InvalidArgument("Created to must be a date or timestamp", err)
+		}
+		countSql = countSql.Where(sq.Lt{"created_at": createdTo})
+		psql = psql.Where(sq.Lt{"created_at": createdTo})
+	}
+
+	var cursor *Cursor
+	if filter.Cursor != "" {
+		cursor, err = r.decodeCursor(filter.Cursor, keys.sort, filter.digest())
+		if err != nil {
+			return nil, err
+		}
+		psql = psql.Where(keys.where(cursor))
+	}
+	psql = psql.OrderBy(keys.orderBy(cursor != nil && cursor.Prev)...)
+
+	page, err := parsePage(filter.Page)
+	if err != nil {
+		return nil, err
+	}
+	if page > 1 && cursor == nil {
+		psql = psql.Offset(uint64((page - 1) * perPage))
 	}
 
 	var totalCnt int
@@ -95,7 +145,7 @@ func (r *Repository) GetSubscriptions(ctx context.Context, filter GetSubscriptio
 		if err != nil {
 			return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 		}
-		rows, err := tx.Query(ctx, sqlStmt, sqlArgs...)
+		rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 		}
@@ -104,13 +154,13 @@ func (r *Repository) GetSubscriptions(ctx context.Context, filter GetSubscriptio
 		}
 	}
 
-	var subscriptions []Subscription
+	subscriptions := []Subscription{}
 	{
 		sqlStmt, sqlArgs, err := psql.ToSql()
 		if err != nil {
 			return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 		}
-		rows, err := tx.Query(ctx, sqlStmt, sqlArgs...)
+		rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 		}
@@ -119,42 +169,138 @@ func (r *Repository) GetSubscriptions(ctx context.Context, filter GetSubscriptio
 		}
 	}
 
+	more := len(subscriptions) > perPage
+	if more {
+		subscriptions = subscriptions[:perPage]
+	}
+	if cursor != nil && cursor.Prev {
+		for i, j := 0, len(subscriptions)-1; i < j; i, j = i+1, j-1 {
+			subscriptions[i], subscriptions[j] = subscriptions[j], subscriptions[i]
+		}
+	}
+
+	metadata := newMetadata(page, perPage, totalCnt)
+	if len(subscriptions) > 0 {
+		first, last := subscriptions[0], subscriptions[len(subscriptions)-1]
+		r.setCursors(&metadata, cursor, page, more,
+			Cursor{Sort: keys.sort, Filters: filter.digest(), Id: first.Id, CreatedAt: &first.CreatedAt},
+			Cursor{Sort: keys.sort, Filters: filter.digest(), Id: last.Id, CreatedAt: &last.CreatedAt},
+		)
+	}
+
 	return &GetSubscriptionsResponse{
-		Data: subscriptions,
-		Metadata: GetMetadata{
-			Page:  offset,
-			Total: totalCnt,
-		},
+		Data:     subscriptions,
+		Metadata: metadata,
 	}, nil
 }
 
+func subscriptionKeyset(sort string) keyset {
+	switch sort {
+	case "new":
+		return keyset{sort: "new", cols: []string{"created_at", "id"}, desc: true}
+	case "old":
+		return keyset{sort: "old", cols: []string{"created_at", "id"}}
+	default:
+		return keyset{sort: "id", cols: []string{"id"}}
+	}
+}
+
+func (r *Repository) GetSubscription(ctx context.Context, id string) (subscription *Subscription, err error) {
+	db := r.reader(ctx)
+
+	if _, err := strconv.Atoi(id); err != nil {
+		return nil, errs.InvalidArgument("Subscription id must be a number", err)
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select(columnNames(subscriptionColumns)...).
+		From(`"subscription"`).
+		Where(sq.Eq{"id": id})
+
+	sqlStmt, sqlArgs, err := psql.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	subscription = &Subscription{}
+	if err := pgxscan.Get(ctx, db, subscription, sqlStmt, sqlArgs...); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, fmt.Errorf("subscription %s | %w", id, ErrSubscriptionNotFound)
+		}
+		return nil, fmt.Errorf("failed to execute: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	return subscription, nil
+}
+
+// GetActiveSubscriptions returns every subscription still waiting on an
+// opening. Subscriptions created before status existed have no status and are
+// treated as active.
+func (r *Repository) GetActiveSubscriptions(ctx context.Context, filter GetActiveSubscriptionsFilter) (subscriptions []Subscription, err error) {
+	db := r.reader(ctx)
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select(columnNames(subscriptionColumns)...).
+		From(`"subscription"`).
+		Where(sq.Or{sq.Eq{"status": nil}, sq.Eq{"status": SubscriptionStatusActive}}).
+		OrderBy("id")
+
+	if filter.FacilityId != 0 {
+		psql = psql.Where(sq.Eq{"facility_id": filter.FacilityId})
+	}
+
+	if len(filter.TargetDates) > 0 {
+		psql = psql.Where(sq.Eq{"target_date": filter.TargetDates})
+	}
+
+	sqlStmt, sqlArgs, err := psql.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+	}
+
+	if err := pgxscan.ScanAll(&subscriptions, rows); err != nil {
+		return nil, fmt.Errorf("failed to scan rows | %w", err)
+	}
+
+	return subscriptions, nil
+}
+
 func (r *Repository) CreateSubscription(ctx context.Context, payload CreateSubscriptionPayload) (subscription *Subscription, err error) {
-	tx, ok := ctx.Value(TxnKey).(pgx.Tx)
-	if !ok || tx == nil {
-		tx, _ = r.db.Begin(ctx)
-		defer func() error {
-			if err != nil {
-				return tx.Rollback(ctx)
-			}
-			return tx.Commit(ctx)
-		}()
+	tx, endTxn, err := r.getTxn(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer endTxn(&err)
 
-	cols := []string{"email", "target_date", "facility_id", "status"}
-	vals := []interface{}{payload.Email, payload.TargetDate, payload.FacilityId, payload.Status}
+	cols := []string{"email", "target_date", "facility_id", "campsite_ids", "site_type"}
+	vals := []interface{}{payload.Email, payload.TargetDate, payload.FacilityId, payload.CampsiteIds, payload.SiteType}
+
+	// Leave status out when not given so the column default applies.
+	if payload.Status != nil {
+		cols = append(cols, "status")
+		vals = append(vals, payload.Status)
+	}
 
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 	sqlStmt, sqlArgs, err := psql.Insert(`"subscription"`).
 		Columns(cols...).
 		Values(vals...).
-		Suffix("RETURNING id").
+		Suffix("RETURNING " + strings.Join(columnNames(subscriptionColumns), ", ")).
 		ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 	}
 
 	var newSubscription Subscription
-	if err := tx.QueryRow(ctx, sqlStmt, sqlArgs...).Scan(&newSubscription.Id); err != nil {
+	if err := pgxscan.Get(ctx, tx, &newSubscription, sqlStmt, sqlArgs...); err != nil {
+		if isForeignKeyViolation(err, "subscription_facility_id_fkey") {
+			return nil, fmt.Errorf("facility %d | %w", payload.FacilityId, ErrFacilityNotFound)
+		}
 		return nil, fmt.Errorf("failed to execute: %s args: %v | %w", sqlStmt, sqlArgs, err)
 	}
 
@@ -162,15 +308,14 @@ func (r *Repository) CreateSubscription(ctx context.Context, payload CreateSubsc
 }
 
 func (r *Repository) UpdateSubscription(ctx context.Context, id string, payload UpdateSubscriptionPayload) (subscription *Subscription, err error) {
-	tx, ok := ctx.Value(TxnKey).(pgx.Tx)
-	if !ok || tx == nil {
-		tx, _ = r.db.Begin(ctx)
-		defer func() error {
-			if err != nil {
-				return tx.Rollback(ctx)
-			}
-			return tx.Commit(ctx)
-		}()
+	tx, endTxn, err := r.getTxn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer endTxn(&err)
+
+	if _, err := strconv.Atoi(id); err != nil {
+		return nil, errs.InvalidArgument("Subscription id must be a number", err)
 	}
 
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
@@ -181,13 +326,16 @@ func (r *Repository) UpdateSubscription(ctx context.Context, id string, payload
 		psql = psql.Set("status", payload.Status)
 	}
 
-	sqlStmt, sqlArgs, err := psql.Suffix("RETURNING id").ToSql()
+	sqlStmt, sqlArgs, err := psql.Suffix("RETURNING " + strings.Join(columnNames(subscriptionColumns), ", ")).ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 	}
 
 	var updatedSubscription Subscription
-	if err := tx.QueryRow(ctx, sqlStmt, sqlArgs...).Scan(&updatedSubscription.Id); err != nil {
+	if err := pgxscan.Get(ctx, tx, &updatedSubscription, sqlStmt, sqlArgs...); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, fmt.Errorf("subscription %s | %w", id, ErrSubscriptionNotFound)
+		}
 		return nil, fmt.Errorf("failed to execute: %s args: %v | %w", sqlStmt, sqlArgs, err)
 	}
 
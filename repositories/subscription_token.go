@@ -5,20 +5,42 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"strings"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/georgysavva/scany/pgxscan"
-	"github.com/jackc/pgx/v4"
+	"github.com/katakeda/lantrn-api-go/errs"
+)
+
+const (
+	SubscriptionTokenPurposeManage      = "manage"
+	SubscriptionTokenPurposeUnsubscribe = "unsubscribe"
+	SubscriptionTokenPurposeVerify      = "verify"
 )
 
 type SubscriptionToken struct {
-	Id             int    `json:"id" db:"id"`
-	SubscriptionId int    `json:"subscriptionId" db:"subscription_id"`
-	Token          string `json:"token" db:"token"`
+	Id             int       `json:"id" db:"id"`
+	SubscriptionId int       `json:"subscriptionId" db:"subscription_id"`
+	Token          string    `json:"token" db:"token"`
+	Purpose        string    `json:"purpose" db:"purpose"`
+	CreatedAt      time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time `json:"updatedAt" db:"updated_at"`
 }
 
 type GetSubscriptionTokensFilter struct {
-	Token string
+	Token           string
+	SubscriptionIds string
+	Purpose         string
+	CreatedFrom     string
+	CreatedTo       string
+	Page            string
+	PerPage         string
+	Cursor          string
+}
+
+func (f GetSubscriptionTokensFilter) digest() string {
+	return filterDigest("token", f.Token, "subscription_ids", f.SubscriptionIds, "purpose", f.Purpose, "created_from", f.CreatedFrom, "created_to", f.CreatedTo)
 }
 
 type GetSubscriptionTokensResponse struct {
@@ -27,43 +49,111 @@ type GetSubscriptionTokensResponse struct {
 }
 
 type CreateSubscriptionTokenPayload struct {
-	SubscriptionId int `json:"subscriptionId"`
+	SubscriptionId int     `json:"subscriptionId"`
+	Purpose        *string `json:"purpose"`
 }
 
 func (r *Repository) GetSubscriptionTokens(ctx context.Context, filter GetSubscriptionTokensFilter) (response *GetSubscriptionTokensResponse, err error) {
-	tx, ok := ctx.Value(TxnKey).(pgx.Tx)
-	if !ok || tx == nil {
-		tx, _ = r.db.Begin(ctx)
-		defer func() error {
-			if err != nil {
-				return tx.Rollback(ctx)
-			}
-			return tx.Commit(ctx)
-		}()
-	}
+	db := r.reader(ctx)
 
 	cols := []string{
 		"id",
 		"subscription_id",
 		"token",
+		"purpose",
+		"created_at",
+		"updated_at",
 	}
 
+	perPage, err := parsePerPage(filter.PerPage, r.defaultPerPage, r.maxPerPage)
+	if err != nil {
+		return nil, err
+	}
+
+	countSql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select("COUNT(*)").
+		From(`"subscription_token"`)
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
 		Select(cols...).
 		From(`"subscription_token"`).
-		Limit(perPageMax)
+		Limit(uint64(perPage) + 1)
 
 	if filter.Token != "" {
+		countSql = countSql.Where(sq.Eq{"token": filter.Token})
 		psql = psql.Where(sq.Eq{"token": filter.Token})
 	}
 
-	var subscriptionTokens []SubscriptionToken
+	if filter.SubscriptionIds != "" {
+		subscriptionIds := strings.Split(filter.SubscriptionIds, ",")
+		countSql = countSql.Where(sq.Eq{"subscription_id": subscriptionIds})
+		psql = psql.Where(sq.Eq{"subscription_id": subscriptionIds})
+	}
+
+	if filter.Purpose != "" {
+		countSql = countSql.Where(sq.Eq{"purpose": filter.Purpose})
+		psql = psql.Where(sq.Eq{"purpose": filter.Purpose})
+	}
+
+	if filter.CreatedFrom != "" {
+		createdFrom, err := parseTime(filter.CreatedFrom)
+		if err != nil {
+			return nil, errs.InvalidArgument("Created from must be a date or timestamp", err)
+		}
+		countSql = countSql.Where(sq.GtOrEq{"created_at": createdFrom})
+		psql = psql.Where(sq.GtOrEq{"created_at": createdFrom})
+	}
+
+	if filter.CreatedTo != "" {
+		createdTo, err := parseTime(filter.CreatedTo)
+		if err != nil {
+			return nil, errs.InvalidArgument("Created to must be a date or timestamp", err)
+		}
+		countSql = countSql.Where(sq.Lt{"created_at": createdTo})
+		psql = psql.Where(sq.Lt{"created_at": createdTo})
+	}
+
+	keys := keyset{sort: "id", cols: []string{"id"}}
+
+	var cursor *Cursor
+	if filter.Cursor != "" {
+		cursor, err = r.decodeCursor(filter.Cursor, keys.sort, filter.digest())
+		if err != nil {
+			return nil, err
+		}
+		psql = psql.Where(keys.where(cursor))
+	}
+	psql = psql.OrderBy(keys.orderBy(cursor != nil && cursor.Prev)...)
+
+	page, err := parsePage(filter.Page)
+	if err != nil {
+		return nil, err
+	}
+	if page > 1 && cursor == nil {
+		psql = psql.Offset(uint64((page - 1) * perPage))
+	}
+
+	var totalCnt int
+	{
+		sqlStmt, sqlArgs, err := countSql.ToSql()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+		}
+		rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
+		}
+		if err := pgxscan.ScanOne(&totalCnt, rows); err != nil {
+			return nil, fmt.Errorf("failed to scan rows | %w", err)
+		}
+	}
+
+	subscriptionTokens := []SubscriptionToken{}
 	{
 		sqlStmt, sqlArgs, err := psql.ToSql()
 		if err != nil {
 			return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 		}
-		rows, err := tx.Query(ctx, sqlStmt, sqlArgs...)
+		rows, err := db.Query(ctx, sqlStmt, sqlArgs...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 		}
@@ -72,42 +162,60 @@ func (r *Repository) GetSubscriptionTokens(ctx context.Context, filter GetSubscr
 		}
 	}
 
+	more := len(subscriptionTokens) > perPage
+	if more {
+		subscriptionTokens = subscriptionTokens[:perPage]
+	}
+	if cursor != nil && cursor.Prev {
+		for i, j := 0, len(subscriptionTokens)-1; i < j; i, j = i+1, j-1 {
+			subscriptionTokens[i], subscriptionTokens[j] = subscriptionTokens[j], subscriptionTokens[i]
+		}
+	}
+
+	metadata := newMetadata(page, perPage, totalCnt)
+	if len(subscriptionTokens) > 0 {
+		r.setCursors(&metadata, cursor, page, more,
+			Cursor{Sort: keys.sort, Filters: filter.digest(), Id: subscriptionTokens[0].Id},
+			Cursor{Sort: keys.sort, Filters: filter.digest(), Id: subscriptionTokens[len(subscriptionTokens)-1].Id},
+		)
+	}
+
 	return &GetSubscriptionTokensResponse{
-		Data: subscriptionTokens,
-		Metadata: GetMetadata{
-			Page:  0,
-			Total: 1,
-		},
+		Data:     subscriptionTokens,
+		Metadata: metadata,
 	}, nil
 }
 
 func (r *Repository) CreateSubscriptionToken(ctx context.Context, payload CreateSubscriptionTokenPayload) (subscription *SubscriptionToken, err error) {
-	tx, ok := ctx.Value(TxnKey).(pgx.Tx)
-	if !ok || tx == nil {
-		tx, _ = r.db.Begin(ctx)
-		defer func() error {
-			if err != nil {
-				return tx.Rollback(ctx)
-			}
-			return tx.Commit(ctx)
-		}()
+	tx, endTxn, err := r.getTxn(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer endTxn(&err)
 
-	cols := []string{"subscription_id", "token"}
-	vals := []interface{}{payload.SubscriptionId, generateToken()}
+	purpose := SubscriptionTokenPurposeManage
+	if payload.Purpose != nil {
+		purpose = *payload.Purpose
+	}
+
+	cols := []string{"subscription_id", "token", "purpose"}
+	vals := []interface{}{payload.SubscriptionId, generateToken(), purpose}
 
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 	sqlStmt, sqlArgs, err := psql.Insert(`"subscription_token"`).
 		Columns(cols...).
 		Values(vals...).
-		Suffix("RETURNING id, token").
+		Suffix("RETURNING id, subscription_id, token, purpose, created_at, updated_at").
 		ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build query: %s args: %v | %w", sqlStmt, sqlArgs, err)
 	}
 
 	var newSubscriptionToken SubscriptionToken
-	if err := tx.QueryRow(ctx, sqlStmt, sqlArgs...).Scan(&newSubscriptionToken.Id, &newSubscriptionToken.Token); err != nil {
+	if err := pgxscan.Get(ctx, tx, &newSubscriptionToken, sqlStmt, sqlArgs...); err != nil {
+		if isForeignKeyViolation(err, "subscription_token_subscription_id_fkey") {
+			return nil, fmt.Errorf("subscription %d | %w", payload.SubscriptionId, ErrSubscriptionNotFound)
+		}
 		return nil, fmt.Errorf("failed to execute: %s args: %v | %w", sqlStmt, sqlArgs, err)
 	}
 
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+func (s *Service) GetAvailability(c *gin.Context) {
+	s.getAvailability(c)
+}
+
+func (s *Service) GetAvailabilityHistory(c *gin.Context) {
+	s.getAvailabilityHistory(c)
+}
+
+func (s *Service) getAvailability(c *gin.Context) (err error) {
+	defer func() {
+		if err != nil {
+			slog.ErrorContext(c, "Failed to get availability", "error", err)
+			abort(c, err, "Something went wrong while getting availability")
+		}
+	}()
+
+	if _, err := s.repo.GetFacility(c, c.Param("id")); err != nil {
+		return fmt.Errorf("failed to get facility | %w", err)
+	}
+
+	params := c.Request.URL.Query()
+	response, err := s.repo.GetAvailability(c, repositories.GetAvailabilityFilter{
+		FacilityId: c.Param("id"),
+		From:       params.Get("from"),
+		To:         params.Get("to"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch availability | %w", err)
+	}
+
+	c.JSON(http.StatusOK, response)
+
+	return nil
+}
+
+func (s *Service) getAvailabilityHistory(c *gin.Context) (err error) {
+	defer func() {
+		if err != nil {
+			slog.ErrorContext(c, "Failed to get availability history", "error", err)
+			abort(c, err, "Something went wrong while getting availability history")
+		}
+	}()
+
+	if _, err := s.repo.GetFacility(c, c.Param("id")); err != nil {
+		return fmt.Errorf("failed to get facility | %w", err)
+	}
+
+	params := c.Request.URL.Query()
+	response, err := s.repo.GetAvailabilityHistory(c, repositories.GetAvailabilityFilter{
+		FacilityId: c.Param("id"),
+		From:       params.Get("from"),
+		To:         params.Get("to"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch availability history | %w", err)
+	}
+
+	c.JSON(http.StatusOK, response)
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+func (s *Service) GetCampsites(c *gin.Context) {
+	s.getCampsites(c)
+}
+
+func (s *Service) getCampsites(c *gin.Context) (err error) {
+	defer func() {
+		if err != nil {
+			slog.ErrorContext(c, "Failed to get campsites", "error", err)
+			abort(c, err, "Something went wrong while getting campsites")
+		}
+	}()
+
+	if _, err := s.repo.GetFacility(c, c.Param("id")); err != nil {
+		return fmt.Errorf("failed to get facility | %w", err)
+	}
+
+	params := c.Request.URL.Query()
+	response, err := s.repo.GetCampsites(c, repositories.GetCampsitesFilter{
+		FacilityId: c.Param("id"),
+		SiteType:   params.Get("site_type"),
+		Loop:       params.Get("loop"),
+		Page:       params.Get("page"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch campsites | %w", err)
+	}
+
+	renderList(c, response.Data, len(response.Data), response.Metadata, "No campsites found")
+
+	return nil
+}
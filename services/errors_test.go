@@ -0,0 +1,105 @@
+package services_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/katakeda/lantrn-api-go/errs"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		code errs.Code
+		want int
+	}{
+		{code: errs.CodeNotFound, want: http.StatusNotFound},
+		{code: errs.CodeInvalidArgument, want: http.StatusBadRequest},
+		{code: errs.CodeValidation, want: http.StatusUnprocessableEntity},
+		{code: errs.CodeConflict, want: http.StatusConflict},
+		{code: errs.CodeUnauthorized, want: http.StatusUnauthorized},
+		{code: errs.CodeInternal, want: http.StatusInternalServerError},
+		{code: "unknown", want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			if got := errs.New(tt.code, "message", nil).Status(); got != tt.want {
+				t.Errorf("Status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorWrapping(t *testing.T) {
+	cause := errors.New("connection reset")
+
+	tests := []struct {
+		name     string
+		err      error
+		sentinel error
+		code     errs.Code
+		message  string
+	}{
+		{
+			name:     "facility not found",
+			err:      fmt.Errorf("failed to fetch facility | %w", repositories.ErrFacilityNotFound),
+			sentinel: repositories.ErrFacilityNotFound,
+			code:     errs.CodeNotFound,
+			message:  "Facility not found",
+		},
+		{
+			name:     "subscription not found",
+			err:      fmt.Errorf("failed to create subscription token | %w", fmt.Errorf("subscription 999 | %w", repositories.ErrSubscriptionNotFound)),
+			sentinel: repositories.ErrSubscriptionNotFound,
+			code:     errs.CodeNotFound,
+			message:  "Subscription not found",
+		},
+		{
+			name:    "sentinel with details",
+			err:     fmt.Errorf("failed to fetch facility | %w", repositories.ErrFacilityNotFound.WithDetails("232447")),
+			code:    errs.CodeNotFound,
+			message: "Facility not found",
+		},
+		{
+			name:     "cause of a typed error",
+			err:      fmt.Errorf("failed to fetch facilities | %w", errs.InvalidArgument("Invalid cursor", cause)),
+			sentinel: cause,
+			code:     errs.CodeInvalidArgument,
+			message:  "Invalid cursor",
+		},
+		{
+			name:     "untyped error",
+			err:      fmt.Errorf("failed to fetch facilities | %w", cause),
+			sentinel: cause,
+			code:     errs.CodeInternal,
+			message:  "Something went wrong",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.sentinel != nil && !errors.Is(tt.err, tt.sentinel) {
+				t.Errorf("errors.Is(%v, %v) = false, want true", tt.err, tt.sentinel)
+			}
+
+			e := errs.From(tt.err, "Something went wrong")
+			if e.Code != tt.code || e.Message != tt.message {
+				t.Errorf("From = %s %q, want %s %q", e.Code, e.Message, tt.code, tt.message)
+			}
+
+			var target *errs.Error
+			if tt.code != errs.CodeInternal && (!errors.As(tt.err, &target) || target != e) {
+				t.Errorf("errors.As = %v, want the error From found", target)
+			}
+		})
+	}
+
+	// A copy with details is a different error from the sentinel it was made
+	// from, so callers match it by code rather than with errors.Is.
+	if errors.Is(repositories.ErrFacilityNotFound.WithDetails("232447"), repositories.ErrFacilityNotFound) {
+		t.Error("errors.Is matched a copy of ErrFacilityNotFound")
+	}
+}
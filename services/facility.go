@@ -2,7 +2,7 @@ package services
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -20,30 +20,32 @@ func (s *Service) GetFacility(c *gin.Context) {
 func (s *Service) getFacilities(c *gin.Context) (err error) {
 	defer func() {
 		if err != nil {
-			log.Println("Failed to get facilities |", err)
-			c.JSON(http.StatusInternalServerError, "Something went wrong while getting facilities")
+			slog.ErrorContext(c, "Failed to get facilities", "error", err)
+			abort(c, err, "Something went wrong while getting facilities")
 		}
 	}()
 
 	params := c.Request.URL.Query()
 	response, err := s.repo.GetFacilities(c, repositories.GetFacilitiesFilter{
-		Lat:  params.Get("lat"),
-		Lng:  params.Get("lng"),
-		Ids:  params.Get("ids"),
-		Sort: params.Get("sort"),
-		Page: params.Get("page"),
+		Lat:     params.Get("lat"),
+		Lng:     params.Get("lng"),
+		Ids:     params.Get("ids"),
+		Sort:    params.Get("sort"),
+		Page:    params.Get("page"),
+		PerPage: params.Get("per_page"),
+		Cursor:  params.Get("cursor"),
+		Fields:  params.Get("fields"),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to fetch facilities | %w", err)
 	}
 
-	if len(response.Data) <= 0 {
-		log.Println("No facilities found")
-		c.JSON(http.StatusNotFound, "No facilities found")
-		return
+	data, err := sparse(versionedList(c, response.Data, newLegacyFacility), params.Get("fields"))
+	if err != nil {
+		return fmt.Errorf("failed to select fields | %w", err)
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderList(c, data, len(response.Data), response.Metadata, "No facilities found")
 
 	return nil
 }
@@ -51,8 +53,8 @@ func (s *Service) getFacilities(c *gin.Context) (err error) {
 func (s *Service) getFacility(c *gin.Context) (err error) {
 	defer func() {
 		if err != nil {
-			log.Println("Failed to get facility |", err)
-			c.JSON(http.StatusInternalServerError, "Something went wrong while getting facility")
+			slog.ErrorContext(c, "Failed to get facility", "error", err)
+			abort(c, err, "Something went wrong while getting facility")
 		}
 	}()
 
@@ -62,7 +64,7 @@ func (s *Service) getFacility(c *gin.Context) (err error) {
 		return fmt.Errorf("failed to get facility | %w", err)
 	}
 
-	c.JSON(http.StatusOK, facility)
+	c.JSON(http.StatusOK, versioned(c, *facility, newLegacyFacility))
 
 	return nil
 }
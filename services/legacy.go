@@ -0,0 +1,91 @@
+package services
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/katakeda/lantrn-api-go/errs"
+	"github.com/katakeda/lantrn-api-go/middlewares"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+// Version 1 answers with resources as they were before version 2 added
+// fields to them, so the bodies old clients decode don't change.
+
+type legacyFacility struct {
+	Id          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description *string  `json:"description"`
+	Latitude    *float32 `json:"latitude"`
+	Longitude   *float32 `json:"longitude"`
+	FacilityId  string   `json:"facilityId"`
+	PrimaryImg  *string  `json:"primaryImg"`
+}
+
+type legacySubscription struct {
+	Id         int     `json:"id"`
+	Email      string  `json:"email"`
+	TargetDate string  `json:"targetDate"`
+	FacilityId int     `json:"facilityId"`
+	Status     *string `json:"status"`
+}
+
+type legacySubscriptionToken struct {
+	Id             int    `json:"id"`
+	SubscriptionId int    `json:"subscriptionId"`
+	Token          string `json:"token"`
+}
+
+func newLegacyFacility(facility repositories.Facility) legacyFacility {
+	return legacyFacility{
+		Id:          facility.Id,
+		Name:        facility.Name,
+		Description: facility.Description,
+		Latitude:    facility.Latitude,
+		Longitude:   facility.Longitude,
+		FacilityId:  facility.FacilityId,
+		PrimaryImg:  facility.PrimaryImg,
+	}
+}
+
+func newLegacySubscription(subscription repositories.Subscription) legacySubscription {
+	return legacySubscription{
+		Id:         subscription.Id,
+		Email:      subscription.Email,
+		TargetDate: subscription.TargetDate,
+		FacilityId: subscription.FacilityId,
+		Status:     subscription.Status,
+	}
+}
+
+func newLegacySubscriptionToken(subscriptionToken repositories.SubscriptionToken) legacySubscriptionToken {
+	return legacySubscriptionToken{
+		Id:             subscriptionToken.Id,
+		SubscriptionId: subscriptionToken.SubscriptionId,
+		Token:          subscriptionToken.Token,
+	}
+}
+
+// versioned returns resource as version 2 renders it, or converted by legacy
+// for version 1.
+func versioned[T, L any](c *gin.Context, resource T, legacy func(T) L) interface{} {
+	if middlewares.RequestedAPIVersion(c) >= middlewares.APIVersion2 {
+		return resource
+	}
+	return legacy(resource)
+}
+
+// versionedList is versioned for every resource of a listing.
+func versionedList[T, L any](c *gin.Context, resources []T, legacy func(T) L) interface{} {
+	return versioned(c, resources, func(resources []T) []L {
+		converted := make([]L, len(resources))
+		for idx, resource := range resources {
+			converted[idx] = legacy(resource)
+		}
+		return converted
+	})
+}
+
+// abort attaches err for ErrorHandler to render. message is what version 1
+// answered with for any failure of the handler, before errors were typed.
+func abort(c *gin.Context, err error, message string) {
+	c.Error(errs.From(err, message)).SetMeta(middlewares.LegacyError{Message: message})
+}
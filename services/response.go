@@ -0,0 +1,100 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/katakeda/lantrn-api-go/middlewares"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+type listResponse struct {
+	Data     interface{} `json:"data"`
+	Metadata interface{} `json:"metadata"`
+}
+
+type legacyMetadata struct {
+	Page  int `json:"page"`
+	Total int `json:"total"`
+}
+
+// renderCreated answers a create. Version 2 answers 201 and points Location
+// at the new resource, version 1 keeps answering 200.
+func renderCreated(c *gin.Context, location string, resource interface{}) {
+	if middlewares.RequestedAPIVersion(c) >= middlewares.APIVersion2 {
+		c.Header("Location", location)
+		c.JSON(http.StatusCreated, resource)
+		return
+	}
+
+	c.JSON(http.StatusOK, resource)
+}
+
+// renderList answers a listing. Version 1 answers 404 with emptyMessage when
+// nothing matched and only reports page and total, with page 0 when none was
+// asked for, version 2 always answers 200 with the full metadata and cursors
+// so clients can page past the last page.
+func renderList(c *gin.Context, data interface{}, size int, metadata repositories.GetMetadata, emptyMessage string) {
+	if middlewares.RequestedAPIVersion(c) >= middlewares.APIVersion2 {
+		// Pages reached through a cursor have no number.
+		if metadata.Page < 1 && c.Query("cursor") == "" {
+			metadata.Page = 1
+		}
+		c.JSON(http.StatusOK, listResponse{
+			Data:     data,
+			Metadata: metadata,
+		})
+		return
+	}
+
+	if size <= 0 {
+		slog.InfoContext(c, emptyMessage)
+		c.JSON(http.StatusNotFound, emptyMessage)
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse{
+		Data: data,
+		Metadata: legacyMetadata{
+			Page:  metadata.Page,
+			Total: metadata.Total,
+		},
+	})
+}
+
+// sparse keeps only the requested fields of every item in data, a slice of
+// resources, so the response matches the columns that were selected.
+func sparse(data interface{}, fields string) (interface{}, error) {
+	if fields == "" {
+		return data, nil
+	}
+
+	keep := make(map[string]bool)
+	for _, field := range strings.Split(fields, ",") {
+		keep[strings.TrimSpace(field)] = true
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data | %w", err)
+	}
+
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data | %w", err)
+	}
+
+	for _, item := range items {
+		for field := range item {
+			if !keep[field] {
+				delete(item, field)
+			}
+		}
+	}
+
+	return items, nil
+}
@@ -1,9 +1,12 @@
 package services
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"path"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/katakeda/lantrn-api-go/repositories"
@@ -13,6 +16,10 @@ func (s *Service) GetSubscriptions(c *gin.Context) {
 	s.getSubscriptions(c)
 }
 
+func (s *Service) GetSubscription(c *gin.Context) {
+	s.getSubscription(c)
+}
+
 func (s *Service) CreateSubscription(c *gin.Context) {
 	s.createSubscription(c)
 }
@@ -24,8 +31,8 @@ func (s *Service) UpdateSubscription(c *gin.Context) {
 func (s *Service) getSubscriptions(c *gin.Context) (err error) {
 	defer func() {
 		if err != nil {
-			log.Println("Failed to get subscriptions |", err)
-			c.JSON(http.StatusInternalServerError, "Something went wrong while getting subscriptions")
+			slog.ErrorContext(c, "Failed to get subscriptions", "error", err)
+			abort(c, err, "Something went wrong while getting subscriptions")
 		}
 	}()
 
@@ -33,19 +40,43 @@ func (s *Service) getSubscriptions(c *gin.Context) (err error) {
 	response, err := s.repo.GetSubscriptions(c, repositories.GetSubscriptionsFilter{
 		FacilityIds: params.Get("facility_ids"),
 		Status:      params.Get("status"),
+		CreatedFrom: params.Get("created_from"),
+		CreatedTo:   params.Get("created_to"),
+		Sort:        params.Get("sort"),
 		Page:        params.Get("page"),
+		PerPage:     params.Get("per_page"),
+		Cursor:      params.Get("cursor"),
+		Fields:      params.Get("fields"),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to fetch subscriptions | %w", err)
 	}
 
-	if len(response.Data) <= 0 {
-		log.Println("No subscriptions found")
-		c.JSON(http.StatusNotFound, "No subscriptions found")
-		return
+	data, err := sparse(versionedList(c, response.Data, newLegacySubscription), params.Get("fields"))
+	if err != nil {
+		return fmt.Errorf("failed to select fields | %w", err)
+	}
+
+	renderList(c, data, len(response.Data), response.Metadata, "No subscriptions found")
+
+	return nil
+}
+
+func (s *Service) getSubscription(c *gin.Context) (err error) {
+	defer func() {
+		if err != nil {
+			slog.ErrorContext(c, "Failed to get subscription", "error", err)
+			abort(c, err, "Something went wrong while getting subscription")
+		}
+	}()
+
+	id := c.Param("id")
+	subscription, err := s.repo.GetSubscription(c, id)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription | %w", err)
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, versioned(c, *subscription, newLegacySubscription))
 
 	return nil
 }
@@ -53,48 +84,62 @@ func (s *Service) getSubscriptions(c *gin.Context) (err error) {
 func (s *Service) createSubscription(c *gin.Context) (err error) {
 	defer func() {
 		if err != nil {
-			log.Println("Failed to create subscription |", err)
-			c.JSON(http.StatusInternalServerError, "Something went wrong while creating subscription")
+			slog.ErrorContext(c, "Failed to create subscription", "error", err)
+			abort(c, err, "Something went wrong while creating subscription")
 		}
 	}()
 
 	payload := repositories.CreateSubscriptionPayload{}
-	if err := c.BindJSON(&payload); err != nil {
-		return fmt.Errorf("failed to parse payload | %w", err)
+	if err := bindJSON(c, &payload); err != nil {
+		return err
+	}
+
+	if err := s.validateCreateSubscription(c, payload); err != nil {
+		return err
 	}
 
-	ctx, _ := s.repo.BeginTxn(c)
-	subscription, err := s.repo.CreateSubscription(ctx, payload)
+	var subscription *repositories.Subscription
+	err = s.repo.WithTx(c, func(ctx context.Context) (err error) {
+		subscription, err = s.repo.CreateSubscription(ctx, payload)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create subscription | %w", err)
 	}
 
-	c.JSON(http.StatusOK, subscription)
+	renderCreated(c, path.Join(c.FullPath(), strconv.Itoa(subscription.Id)), versioned(c, *subscription, newLegacySubscription))
 
-	return s.repo.CommitTxn(ctx)
+	return nil
 }
 
 func (s *Service) updateSubscription(c *gin.Context) (err error) {
 	defer func() {
 		if err != nil {
-			log.Println("Failed to update subscription |", err)
-			c.JSON(http.StatusInternalServerError, "Something went wrong while updating subscription")
+			slog.ErrorContext(c, "Failed to update subscription", "error", err)
+			abort(c, err, "Something went wrong while updating subscription")
 		}
 	}()
 
 	id := c.Param("id")
 	payload := repositories.UpdateSubscriptionPayload{}
-	if err := c.BindJSON(&payload); err != nil {
-		return fmt.Errorf("failed to parse payload | %w", err)
+	if err := bindJSON(c, &payload); err != nil {
+		return err
+	}
+
+	if err := validateUpdateSubscription(payload); err != nil {
+		return err
 	}
 
-	ctx, _ := s.repo.BeginTxn(c)
-	subscription, err := s.repo.UpdateSubscription(ctx, id, payload)
+	var subscription *repositories.Subscription
+	err = s.repo.WithTx(c, func(ctx context.Context) (err error) {
+		subscription, err = s.repo.UpdateSubscription(ctx, id, payload)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update subscription | %w", err)
 	}
 
-	c.JSON(http.StatusOK, subscription)
+	c.JSON(http.StatusOK, versioned(c, *subscription, newLegacySubscription))
 
-	return s.repo.CommitTxn(ctx)
+	return nil
 }
@@ -1,9 +1,10 @@
 package services
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
 	"github.com/katakeda/lantrn-api-go/repositories"
@@ -20,26 +21,27 @@ func (s *Service) CreateSubscriptionToken(c *gin.Context) {
 func (s *Service) getSubscriptionTokens(c *gin.Context) (err error) {
 	defer func() {
 		if err != nil {
-			log.Println("Failed to get subscription tokens |", err)
-			c.JSON(http.StatusInternalServerError, "Something went wrong while getting subscription tokens")
+			slog.ErrorContext(c, "Failed to get subscription tokens", "error", err)
+			abort(c, err, "Something went wrong while getting subscription tokens")
 		}
 	}()
 
 	params := c.Request.URL.Query()
 	response, err := s.repo.GetSubscriptionTokens(c, repositories.GetSubscriptionTokensFilter{
-		Token: params.Get("token"),
+		Token:           params.Get("token"),
+		SubscriptionIds: params.Get("subscription_ids"),
+		Purpose:         params.Get("purpose"),
+		CreatedFrom:     params.Get("created_from"),
+		CreatedTo:       params.Get("created_to"),
+		Page:            params.Get("page"),
+		PerPage:         params.Get("per_page"),
+		Cursor:          params.Get("cursor"),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to fetch subscription tokens | %w", err)
 	}
 
-	if len(response.Data) <= 0 {
-		log.Println("No subscription tokens found")
-		c.JSON(http.StatusNotFound, "No subscription tokens found")
-		return
-	}
-
-	c.JSON(http.StatusOK, response)
+	renderList(c, versionedList(c, response.Data, newLegacySubscriptionToken), len(response.Data), response.Metadata, "No subscription tokens found")
 
 	return nil
 }
@@ -47,23 +49,32 @@ func (s *Service) getSubscriptionTokens(c *gin.Context) (err error) {
 func (s *Service) createSubscriptionToken(c *gin.Context) (err error) {
 	defer func() {
 		if err != nil {
-			log.Println("Failed to create subscription token |", err)
-			c.JSON(http.StatusInternalServerError, "Something went wrong while creating subscription token")
+			slog.ErrorContext(c, "Failed to create subscription token", "error", err)
+			abort(c, err, "Something went wrong while creating subscription token")
 		}
 	}()
 
 	payload := repositories.CreateSubscriptionTokenPayload{}
-	if err := c.BindJSON(&payload); err != nil {
-		return fmt.Errorf("failed to parse payload | %w", err)
+	if err := bindJSON(c, &payload); err != nil {
+		return err
+	}
+
+	if err := validateCreateSubscriptionToken(payload); err != nil {
+		return err
 	}
 
-	ctx, _ := s.repo.BeginTxn(c)
-	subscriptionToken, err := s.repo.CreateSubscriptionToken(ctx, payload)
+	var subscriptionToken *repositories.SubscriptionToken
+	err = s.repo.WithTx(c, func(ctx context.Context) (err error) {
+		subscriptionToken, err = s.repo.CreateSubscriptionToken(ctx, payload)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create subscription token | %w", err)
 	}
 
-	c.JSON(http.StatusOK, subscriptionToken)
+	// Tokens are looked up by value rather than by id.
+	location := c.FullPath() + "?" + url.Values{"token": {subscriptionToken.Token}}.Encode()
+	renderCreated(c, location, versioned(c, *subscriptionToken, newLegacySubscriptionToken))
 
-	return s.repo.CommitTxn(ctx)
+	return nil
 }
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/katakeda/lantrn-api-go/errs"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+const dateLayout = "2006-01-02"
+
+var subscriptionStatuses = []string{
+	repositories.SubscriptionStatusActive,
+	repositories.SubscriptionStatusPaused,
+	repositories.SubscriptionStatusCancelled,
+}
+
+var subscriptionTokenPurposes = []string{
+	repositories.SubscriptionTokenPurposeManage,
+	repositories.SubscriptionTokenPurposeUnsubscribe,
+	repositories.SubscriptionTokenPurposeVerify,
+}
+
+type fieldErrors []errs.FieldError
+
+func (f *fieldErrors) add(field, message string) {
+	*f = append(*f, errs.FieldError{Field: field, Message: message})
+}
+
+func (f fieldErrors) err() error {
+	if len(f) <= 0 {
+		return nil
+	}
+	return errs.Validation("Invalid payload", f)
+}
+
+// bindJSON decodes the request body into payload, reporting fields of the
+// wrong type as validation errors rather than a generic bad request. The
+// failure is also recorded as a bind error, which version 1 answers with 400
+// as it did when handlers used c.BindJSON.
+func bindJSON(c *gin.Context, payload interface{}) error {
+	err := c.ShouldBindJSON(payload)
+	if err == nil {
+		return nil
+	}
+	c.Error(err).SetType(gin.ErrorTypeBind)
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		fields := fieldErrors{}
+		fields.add(typeErr.Field, fmt.Sprintf("must be a %s", typeErr.Type.String()))
+		return fields.err()
+	}
+
+	return errs.InvalidArgument("Invalid payload", err)
+}
+
+func (s *Service) validateCreateSubscription(c *gin.Context, payload repositories.CreateSubscriptionPayload) error {
+	fields := fieldErrors{}
+
+	validateEmail(&fields, "email", payload.Email)
+	validateTargetDate(&fields, "targetDate", payload.TargetDate)
+	validateStatus(&fields, "status", payload.Status, false)
+
+	if payload.SiteType != nil && strings.TrimSpace(*payload.SiteType) == "" {
+		fields.add("siteType", "must not be empty")
+	}
+
+	if payload.FacilityId <= 0 {
+		fields.add("facilityId", "is required")
+		return fields.err()
+	}
+
+	if _, err := s.repo.GetFacility(c, strconv.Itoa(payload.FacilityId)); err != nil {
+		if errors.Is(err, repositories.ErrFacilityNotFound) {
+			fields.add("facilityId", "does not exist")
+			return fields.err()
+		}
+		return fmt.Errorf("failed to get facility | %w", err)
+	}
+
+	if len(payload.CampsiteIds) > 0 {
+		campsites, err := s.repo.GetFacilityCampsites(c, payload.FacilityId)
+		if err != nil {
+			return fmt.Errorf("failed to get campsites | %w", err)
+		}
+
+		known := make(map[int]bool, len(campsites))
+		for _, campsite := range campsites {
+			known[campsite.Id] = true
+		}
+		for idx, id := range payload.CampsiteIds {
+			if !known[id] {
+				fields.add(fmt.Sprintf("campsiteIds[%d]", idx), "does not exist in this facility")
+			}
+		}
+	}
+
+	return fields.err()
+}
+
+func validateUpdateSubscription(payload repositories.UpdateSubscriptionPayload) error {
+	fields := fieldErrors{}
+
+	validateStatus(&fields, "status", payload.Status, true)
+
+	return fields.err()
+}
+
+func validateCreateSubscriptionToken(payload repositories.CreateSubscriptionTokenPayload) error {
+	fields := fieldErrors{}
+
+	if payload.SubscriptionId <= 0 {
+		fields.add("subscriptionId", "is required")
+	}
+
+	if payload.Purpose != nil && !contains(subscriptionTokenPurposes, *payload.Purpose) {
+		fields.add("purpose", fmt.Sprintf("must be one of %s", strings.Join(subscriptionTokenPurposes, ", ")))
+	}
+
+	return fields.err()
+}
+
+func validateEmail(fields *fieldErrors, field, email string) {
+	if email == "" {
+		fields.add(field, "is required")
+		return
+	}
+
+	// Only a bare address is accepted, not a display name form such as
+	// "Jane <jane@example.com>".
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		fields.add(field, "must be a valid email address")
+	}
+}
+
+func validateTargetDate(fields *fieldErrors, field, targetDate string) {
+	if targetDate == "" {
+		fields.add(field, "is required")
+		return
+	}
+
+	date, err := time.Parse(dateLayout, targetDate)
+	if err != nil {
+		fields.add(field, "must be a date formatted as YYYY-MM-DD")
+		return
+	}
+
+	// Dates are days, so today is not in the future either.
+	if today := time.Now().UTC().Truncate(24 * time.Hour); !date.After(today) {
+		fields.add(field, "must be in the future")
+	}
+}
+
+func validateStatus(fields *fieldErrors, field string, status *string, required bool) {
+	if status == nil {
+		if required {
+			fields.add(field, "is required")
+		}
+		return
+	}
+
+	if !contains(subscriptionStatuses, *status) {
+		fields.add(field, fmt.Sprintf("must be one of %s", strings.Join(subscriptionStatuses, ", ")))
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
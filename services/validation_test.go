@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/katakeda/lantrn-api-go/errs"
+	"github.com/katakeda/lantrn-api-go/repositories"
+)
+
+func TestBindJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		body    string
+		code    errs.Code
+		details interface{}
+	}{
+		{name: "valid", body: `{"email":"jane@example.com","facilityId":1}`},
+		{
+			name:    "number as a string",
+			body:    `{"facilityId":"one"}`,
+			code:    errs.CodeValidation,
+			details: []errs.FieldError{{Field: "facilityId", Message: "must be a int"}},
+		},
+		{
+			name:    "string as a number",
+			body:    `{"email":42}`,
+			code:    errs.CodeValidation,
+			details: []errs.FieldError{{Field: "email", Message: "must be a string"}},
+		},
+		{name: "malformed", body: `{"email":`, code: errs.CodeInvalidArgument},
+		{name: "not an object", body: `[]`, code: errs.CodeInvalidArgument},
+		{name: "empty", body: ``, code: errs.CodeInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			err := bindJSON(c, &repositories.CreateSubscriptionPayload{})
+			if tt.code == "" {
+				if err != nil || len(c.Errors) > 0 {
+					t.Errorf("bindJSON = %v with %v, want nil", err, c.Errors)
+				}
+				return
+			}
+
+			var e *errs.Error
+			if !errors.As(err, &e) || e.Code != tt.code || e.Message != "Invalid payload" {
+				t.Fatalf("bindJSON = %v, want %s Invalid payload", err, tt.code)
+			}
+			if tt.details != nil && !reflect.DeepEqual(e.Details, tt.details) {
+				t.Errorf("details = %+v, want %+v", e.Details, tt.details)
+			}
+			// Version 1 answers 400 for any body that couldn't be bound.
+			if bound := c.Errors.ByType(gin.ErrorTypeBind); len(bound) != 1 {
+				t.Errorf("recorded %d bind errors, want 1", len(bound))
+			}
+		})
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{email: "jane@example.com"},
+		{email: "jane.doe+camping@mail.example.co.uk"},
+		{email: "", want: "is required"},
+		{email: "jane", want: "must be a valid email address"},
+		{email: "jane@", want: "must be a valid email address"},
+		{email: "@example.com", want: "must be a valid email address"},
+		{email: "Jane <jane@example.com>", want: "must be a valid email address"},
+		{email: " jane@example.com", want: "must be a valid email address"},
+		{email: "jane@example.com, joe@example.com", want: "must be a valid email address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			fields := fieldErrors{}
+			validateEmail(&fields, "email", tt.email)
+			assertFieldError(t, fields, "email", tt.want)
+		})
+	}
+}
+
+func TestValidateTargetDate(t *testing.T) {
+	today := time.Now().UTC()
+
+	tests := []struct {
+		name string
+		date string
+		want string
+	}{
+		{name: "tomorrow", date: today.AddDate(0, 0, 1).Format(dateLayout)},
+		{name: "next year", date: today.AddDate(1, 0, 0).Format(dateLayout)},
+		{name: "today", date: today.Format(dateLayout), want: "must be in the future"},
+		{name: "yesterday", date: today.AddDate(0, 0, -1).Format(dateLayout), want: "must be in the future"},
+		{name: "missing", date: "", want: "is required"},
+		{name: "no such month", date: "2030-13-01", want: "must be a date formatted as YYYY-MM-DD"},
+		{name: "us format", date: "07/01/2030", want: "must be a date formatted as YYYY-MM-DD"},
+		{name: "timestamp", date: "2030-07-01T00:00:00Z", want: "must be a date formatted as YYYY-MM-DD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := fieldErrors{}
+			validateTargetDate(&fields, "targetDate", tt.date)
+			assertFieldError(t, fields, "targetDate", tt.want)
+		})
+	}
+}
+
+// assertFieldError checks fields holds only message for field, or nothing
+// when message is empty.
+func assertFieldError(t *testing.T, fields fieldErrors, field, message string) {
+	t.Helper()
+
+	var want fieldErrors
+	if message != "" {
+		want = fieldErrors{{Field: field, Message: message}}
+	}
+	if len(fields) != len(want) || (len(want) > 0 && fields[0] != want[0]) {
+		t.Errorf("errors = %+v, want %+v", fields, want)
+	}
+}